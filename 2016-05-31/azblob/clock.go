@@ -0,0 +1,19 @@
+package azblob
+
+import "time"
+
+// clock abstracts the package's use of the current time and timers so that time-dependent code
+// (retry back-off, request logging, SAS & circuit breaker bookkeeping) can be tested deterministically.
+type clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// realClock is the clock used by default; it defers to the time package.
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// pkgClock is the clock used throughout the package. Only tests replace it.
+var pkgClock clock = realClock{}
@@ -14,7 +14,8 @@ type Credential interface {
 
 // NewAnonymousCredential creates an anonymous credential for use with HTTP(S)
 // requests that read blobs from public containers or for use with Shared Access
-// Signatures (SAS).
+// Signatures (SAS). Listing a container's blobs anonymously is only allowed if the
+// container's access is PublicAccessContainer; see ContainerURL's GetAnonymousAccess.
 func NewAnonymousCredential() Credential {
 	return &anonymousCredentialPolicyFactory{}
 }
@@ -6,10 +6,12 @@ import (
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/base64"
+	"fmt"
 	"net/http"
 	"net/url"
 	"sort"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/Azure/azure-pipeline-go/pipeline"
@@ -26,8 +28,10 @@ func NewSharedKeyCredential(accountName, accountKey string) *SharedKeyCredential
 }
 
 // SharedKeyCredential contains an account's name and its primary or secondary key.
-// It is immutable making it shareable and goroutine-safe.
+// Other than its clock skew (see SetClockSkew), it is immutable making it shareable and goroutine-safe.
 type SharedKeyCredential struct {
+	clockSkew int64 // A time.Duration accessed atomically; it is first to be 64-bit aligned on 32-bit platforms
+
 	// Only the NewSharedKeyCredential method should set these; all other methods should treat them as read-only
 	accountName string
 	accountKey  []byte
@@ -38,6 +42,21 @@ func (f SharedKeyCredential) AccountName() string {
 	return f.accountName
 }
 
+// ClockSkew returns how far the service's clock is ahead of this machine's clock (negative if it is behind), as
+// set by SetClockSkew or detected from a request that failed authentication (see SetClockSkew). Add it to the
+// start and expiry times of SASs signed with this credential if the local clock is known to be wrong.
+func (f *SharedKeyCredential) ClockSkew() time.Duration {
+	return time.Duration(atomic.LoadInt64(&f.clockSkew))
+}
+
+// SetClockSkew sets how far the service's clock is ahead of this machine's clock; the x-ms-date header of each
+// request is the local time plus skew. The service rejects a request whose x-ms-date is more than 15 minutes from
+// its clock. When that happens, the credential's policy sets the skew from the response's Date header and retries
+// the request once, so a machine with a wrong clock usually doesn't need to call SetClockSkew.
+func (f *SharedKeyCredential) SetClockSkew(skew time.Duration) {
+	atomic.StoreInt64(&f.clockSkew, int64(skew))
+}
+
 // New creates a credential policy object.
 func (f *SharedKeyCredential) New(node pipeline.Node) pipeline.Policy {
 	return sharedKeyCredentialPolicy{node: node, factory: f}
@@ -52,11 +71,39 @@ type sharedKeyCredentialPolicy struct {
 	factory *SharedKeyCredential
 }
 
+// maxClockSkew is how far a request's x-ms-date can be from the service's clock before the service rejects it.
+const maxClockSkew = 15 * time.Minute
+
 // Do implements the credential's policy interface.
 func (p sharedKeyCredentialPolicy) Do(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
 	// Add a x-ms-date header if it doesn't already exist
-	if d := request.Header.Get(headerXmsDate); d == "" {
-		request.Header[headerXmsDate] = []string{time.Now().UTC().Format(http.TimeFormat)}
+	setDate := request.Header.Get(headerXmsDate) == ""
+	response, err := p.signAndSend(ctx, request, setDate)
+	if !setDate || !isForbidden(response, err) {
+		return response, err
+	}
+
+	// If the service rejected our x-ms-date because this machine's clock is wrong, correct it and retry once
+	sentDate, _ := http.ParseTime(request.Header.Get(headerXmsDate))
+	serviceDate, parseErr := http.ParseTime(response.Response().Header.Get(headerDate))
+	if parseErr != nil || (serviceDate.Sub(sentDate) <= maxClockSkew && sentDate.Sub(serviceDate) <= maxClockSkew) {
+		return response, err
+	}
+	skew := serviceDate.Sub(pkgClock.Now())
+	p.factory.SetClockSkew(skew)
+	p.node.Log(pipeline.LogWarning, fmt.Sprintf("Clock skew of %v detected from the service's Date header; retrying with a corrected x-ms-date", skew))
+	retry := request.Copy()
+	if rewindErr := retry.RewindBody(); rewindErr != nil {
+		return response, err
+	}
+	return p.signAndSend(ctx, retry, true)
+}
+
+// signAndSend sets the request's x-ms-date (if setDate is true) and Authorization headers and sends it.
+func (p sharedKeyCredentialPolicy) signAndSend(ctx context.Context, request pipeline.Request, setDate bool) (pipeline.Response, error) {
+	if setDate {
+		date := pkgClock.Now().Add(p.factory.ClockSkew()).UTC()
+		request.Header.Set(headerXmsDate, date.Format(http.TimeFormat))
 	}
 	stringToSign := p.factory.buildStringToSign(request)
 	signature := p.factory.ComputeHMACSHA256(stringToSign)
@@ -64,13 +111,17 @@ func (p sharedKeyCredentialPolicy) Do(ctx context.Context, request pipeline.Requ
 	request.Header[headerAuthorization] = []string{authHeader}
 
 	response, err := p.node.Do(ctx, request)
-	if err != nil && response != nil && response.Response() != nil && response.Response().StatusCode == http.StatusForbidden {
+	if isForbidden(response, err) {
 		// Service failed to authenticate request, log it
 		p.node.Log(pipeline.LogError, "===== HTTP Forbidden status, String-to-Sign:\n"+stringToSign+"\n===============================\n")
 	}
 	return response, err
 }
 
+func isForbidden(response pipeline.Response, err error) bool {
+	return err != nil && response != nil && response.Response() != nil && response.Response().StatusCode == http.StatusForbidden
+}
+
 // Constants ensuring that header names are correctly spelled and consistently cased.
 const (
 	headerAuthorization      = "Authorization"
@@ -1,12 +1,29 @@
 package azblob
 
 import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
 	"context"
+	"crypto/md5"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"mime"
 	"net"
 	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/Azure/azure-pipeline-go/pipeline"
 )
@@ -26,11 +43,56 @@ type UploadStreamToBlockBlobOptions struct {
 	// Metadata indicates the metadata to be associated with the blob when PutBlockList is called.
 	Metadata Metadata
 
-	// AccessConditions indicates the access conditions for the block blob.
+	// AccessConditions indicates the access conditions for the block blob. To upload only if the blob doesn't
+	// already exist, set HTTPAccessConditions.IfNoneMatch to ETagAny; if it exists, ErrBlobAlreadyExists is returned.
 	AccessConditions BlobAccessConditions
+
+	// DetectContentType indicates whether the blob's Content-Type is inferred when BlobHTTPHeaders.ContentType is empty.
+	// UploadFileToBlockBlob uses the file name's extension; if the extension is unknown (or there is no file name),
+	// the type is sniffed from the first 512 bytes of the stream.
+	DetectContentType bool
+
+	// MaxBlockCount indicates the maximum number of blocks the blob may be split into (0=default of BlockBlobMaxBlocks).
+	// Raise it only when targeting a service version that allows more blocks per blob.
+	MaxBlockCount int64
+
+	// Overwrite, if not nil, states whether an existing blob may be replaced. A pointer to false creates the blob
+	// only if it doesn't exist (as if AccessConditions.IfNoneMatch were ETagAny; ErrBlobAlreadyExists is returned if
+	// it does); a pointer to true allows overwriting and can't be combined with IfNoneMatch set to ETagAny.
+	// nil leaves AccessConditions as they are, which overwrites an existing blob unless they say otherwise.
+	Overwrite *bool
 }
 
-// UploadStreamToBlockBlob uploads a stream of data in blocks to a block blob.
+// ErrBlobAlreadyExists is returned by UploadStreamToBlockBlob when AccessConditions.IfNoneMatch is ETagAny (or Overwrite
+// is false) and the blob exists. If the service refused the commit, a *BlobAlreadyExistsError is returned instead;
+// use errors.Is(err, ErrBlobAlreadyExists) to detect both.
+var ErrBlobAlreadyExists = errors.New("the blob already exists")
+
+// BlobAlreadyExistsError is returned by UploadStreamToBlockBlob when the service refuses to commit the blocks because
+// another client created the blob while they were being sent. It holds the service's error and matches
+// ErrBlobAlreadyExists with errors.Is.
+type BlobAlreadyExistsError struct {
+	StorageError
+}
+
+// Error implements the error interface's Error method to return a string representation of the error.
+func (e *BlobAlreadyExistsError) Error() string {
+	return ErrBlobAlreadyExists.Error() + ": " + e.StorageError.Error()
+}
+
+// Is returns true if target is ErrBlobAlreadyExists.
+func (e *BlobAlreadyExistsError) Is(target error) bool { return target == ErrBlobAlreadyExists }
+
+// Cause returns the service's error.
+func (e *BlobAlreadyExistsError) Cause() error { return e.StorageError }
+
+// UploadStreamToBlockBlob uploads a stream of data in blocks to a block blob. Each block is read from the
+// stream while it is sent and one block is sent at a time, so no stream data is buffered in memory.
+// To upload from an io.Reader with bounded memory, see PutBlocksFromReader.
+// With AccessConditions.IfNoneMatch set to ETagAny, an existing blob is detected before any block is sent and
+// ErrBlobAlreadyExists is returned. If another client creates the blob while blocks are being sent, the commit fails,
+// the blocks this call sent are left uncommitted (the service discards them after a week) and a
+// *BlobAlreadyExistsError is returned.
 func UploadStreamToBlockBlob(ctx context.Context, stream io.ReaderAt, streamSize int64,
 	blockBlobURL BlockBlobURL, o UploadStreamToBlockBlobOptions) (*BlockBlobsPutBlockListResponse, error) {
 
@@ -38,9 +100,38 @@ func UploadStreamToBlockBlob(ctx context.Context, stream io.ReaderAt, streamSize
 		panic(fmt.Sprintf("BlockSize option must be > 0 and <= %d", BlockBlobMaxPutBlockBytes))
 	}
 
+	if o.MaxBlockCount < 0 {
+		panic("MaxBlockCount must be >= 0")
+	}
+	if o.MaxBlockCount == 0 {
+		o.MaxBlockCount = BlockBlobMaxBlocks
+	}
+	if o.Overwrite != nil {
+		if *o.Overwrite && o.AccessConditions.IfNoneMatch == ETagAny {
+			panic("Overwrite can't be true when AccessConditions.IfNoneMatch is ETagAny")
+		}
+		if !*o.Overwrite {
+			o.AccessConditions.IfNoneMatch = ETagAny
+		}
+	}
+
 	numBlocks := ((streamSize - int64(1)) / o.BlockSize) + 1
-	if numBlocks > BlockBlobMaxBlocks {
-		panic(fmt.Sprintf("The streamSize is too big or the BlockSize is too small; the number of blocks must be <= %d", BlockBlobMaxBlocks))
+	if numBlocks > o.MaxBlockCount {
+		panic(fmt.Sprintf("The streamSize is too big or the BlockSize is too small; the number of blocks must be <= %d", o.MaxBlockCount))
+	}
+	ifNotExists := o.AccessConditions.IfNoneMatch == ETagAny
+	if ifNotExists {
+		// Fail fast rather than sending every block only to have the commit fail
+		_, err := blockBlobURL.GetPropertiesAndMetadata(ctx, BlobAccessConditions{LeaseAccessConditions: o.AccessConditions.LeaseAccessConditions})
+		if err == nil {
+			return nil, ErrBlobAlreadyExists
+		}
+		if se, ok := err.(StorageError); !ok || se.Response() == nil || se.Response().StatusCode != http.StatusNotFound {
+			return nil, err
+		}
+	}
+	if o.DetectContentType && o.BlobHTTPHeaders.ContentType == "" {
+		o.BlobHTTPHeaders.ContentType = detectContentType("", stream, streamSize)
 	}
 	blockIDList := make([]string, numBlocks) // Base 64 encoded block IDs
 	blockSize := o.BlockSize
@@ -66,7 +157,478 @@ func UploadStreamToBlockBlob(ctx context.Context, stream io.ReaderAt, streamSize
 			return nil, err
 		}
 	}
-	return blockBlobURL.PutBlockList(ctx, blockIDList, o.Metadata, o.BlobHTTPHeaders, o.AccessConditions)
+	resp, err := blockBlobURL.PutBlockList(ctx, blockIDList, o.Metadata, o.BlobHTTPHeaders, o.AccessConditions)
+	if se, ok := err.(StorageError); ok && ifNotExists &&
+		(se.ServiceCode() == ServiceCodeBlobAlreadyExists || se.ServiceCode() == ServiceCodeConditionNotMet) {
+		return nil, &BlobAlreadyExistsError{StorageError: se} // Another client created the blob while our blocks were being sent
+	}
+	return resp, err
+}
+
+// UploadFileToBlockBlob uploads a file in blocks to a block blob; see UploadStreamToBlockBlob.
+func UploadFileToBlockBlob(ctx context.Context, file *os.File,
+	blockBlobURL BlockBlobURL, o UploadStreamToBlockBlobOptions) (*BlockBlobsPutBlockListResponse, error) {
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if o.DetectContentType && o.BlobHTTPHeaders.ContentType == "" {
+		o.BlobHTTPHeaders.ContentType = detectContentType(file.Name(), file, stat.Size())
+	}
+	return UploadStreamToBlockBlob(ctx, file, stat.Size(), blockBlobURL, o)
+}
+
+// UploadDirectoryToContainerOptions identifies options used by the UploadDirectoryToContainer function.
+type UploadDirectoryToContainerOptions struct {
+	// Parallelism indicates the maximum number of files uploaded at once (0=default of 5).
+	Parallelism int
+
+	// Include, if not "", is a path.Match pattern (like "*.log") that a file's name must match to be uploaded.
+	Include string
+
+	// Exclude, if not "", is a path.Match pattern; files whose names match it aren't uploaded.
+	Exclude string
+
+	// Upload configures the upload of each file; see UploadFileToBlockBlob. Its BlockSize is mandatory.
+	Upload UploadStreamToBlockBlobOptions
+}
+
+// UploadDirectoryToContainer uploads every file under localDir (including its subdirectories) to a block blob
+// whose name is the file's path relative to localDir with '/' separators. Up to o.Parallelism files are uploaded
+// at once. A failure for one file doesn't stop the others; if any fail, a BlobErrors identifying them is returned.
+// If walking localDir fails or ctx is done, that error is returned instead. The returned blob names, sorted, are
+// the files that were uploaded successfully.
+func UploadDirectoryToContainer(ctx context.Context, containerURL ContainerURL, localDir string,
+	o UploadDirectoryToContainerOptions) ([]string, error) {
+	if o.Parallelism < 0 {
+		panic("Parallelism must be >= 0")
+	}
+	if o.Parallelism == 0 {
+		o.Parallelism = 5
+	}
+	if o.Upload.BlockSize <= 0 || o.Upload.BlockSize > BlockBlobMaxPutBlockBytes {
+		panic(fmt.Sprintf("Upload.BlockSize option must be > 0 and <= %d", BlockBlobMaxPutBlockBytes))
+	}
+
+	names := make(chan string)
+	uploaded := []string{}
+	errs := BlobErrors{}
+	lock := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	for g := 0; g < o.Parallelism; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range names {
+				err := uploadFile(ctx, filepath.Join(localDir, filepath.FromSlash(name)), containerURL.NewBlockBlobURL(name), o.Upload)
+				lock.Lock()
+				if err != nil {
+					errs[name] = err
+				} else {
+					uploaded = append(uploaded, name)
+				}
+				lock.Unlock()
+			}
+		}()
+	}
+
+	walkErr := filepath.Walk(localDir, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		if o.Include != "" {
+			if match, err := path.Match(o.Include, info.Name()); err != nil || !match {
+				return err
+			}
+		}
+		if o.Exclude != "" {
+			if match, err := path.Match(o.Exclude, info.Name()); err != nil || match {
+				return err
+			}
+		}
+		rel, err := filepath.Rel(localDir, filePath)
+		if err != nil {
+			return err
+		}
+		select {
+		case names <- filepath.ToSlash(rel):
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	close(names)
+	wg.Wait()
+	sort.Strings(uploaded)
+	if walkErr != nil {
+		return uploaded, walkErr
+	}
+	if len(errs) > 0 {
+		return uploaded, errs
+	}
+	return uploaded, nil
+}
+
+// uploadFile uploads the file at filePath to blockBlobURL.
+func uploadFile(ctx context.Context, filePath string, blockBlobURL BlockBlobURL, o UploadStreamToBlockBlobOptions) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = UploadFileToBlockBlob(ctx, file, blockBlobURL, o)
+	return err
+}
+
+// DownloadContainerToDirectoryOptions identifies options used by the DownloadContainerToDirectory function.
+type DownloadContainerToDirectoryOptions struct {
+	// Parallelism indicates the maximum number of blobs downloaded at once (0=default of 5).
+	Parallelism int
+
+	// SkipUnchanged indicates whether a blob is skipped if its local file has the blob's size and last modified
+	// time. DownloadContainerToDirectory sets each file's modification time to its blob's Last-Modified time so
+	// that a later call with SkipUnchanged downloads only the blobs that changed since.
+	SkipUnchanged bool
+
+	// Progress, if not nil, is invoked periodically with a blob's name and the bytes of it written so far.
+	// Although blobs are downloaded in parallel, calls to it are serialized.
+	Progress func(blobName string, bytesTransferred int64)
+
+	// Download configures the download of each blob; see DownloadBlobToFile. Its Progress is ignored.
+	Download DownloadBlobToFileOptions
+}
+
+// DownloadContainerToDirectory downloads every blob whose name starts with prefix to the file under localDir
+// whose relative path is the blob's name ('/' separators become directories, which are created as needed).
+// Up to o.Parallelism blobs are downloaded at once. A failure for one blob doesn't stop the others; if any fail,
+// a BlobErrors identifying them is returned. A blob whose name would place its file outside localDir fails
+// without being downloaded. If listing the blobs fails or ctx is done, that error is returned instead.
+// The returned blob names, sorted, are the blobs that were downloaded successfully (skipped blobs are omitted).
+func DownloadContainerToDirectory(ctx context.Context, containerURL ContainerURL, prefix, localDir string,
+	o DownloadContainerToDirectoryOptions) ([]string, error) {
+	if o.Parallelism < 0 {
+		panic("Parallelism must be >= 0")
+	}
+	if o.Parallelism == 0 {
+		o.Parallelism = 5
+	}
+	if progress := o.Progress; progress != nil {
+		progressLock := sync.Mutex{}
+		o.Progress = func(blobName string, bytesTransferred int64) {
+			progressLock.Lock()
+			defer progressLock.Unlock()
+			progress(blobName, bytesTransferred)
+		}
+	}
+
+	blobs := make(chan Blob)
+	downloaded := []string{}
+	errs := BlobErrors{}
+	lock := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	for g := 0; g < o.Parallelism; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for blob := range blobs {
+				skipped, err := downloadFile(ctx, containerURL.NewBlobURL(blob.Name), blob, localDir, o)
+				lock.Lock()
+				if err != nil {
+					errs[blob.Name] = err
+				} else if !skipped {
+					downloaded = append(downloaded, blob.Name)
+				}
+				lock.Unlock()
+			}
+		}()
+	}
+
+	var listErr error
+list:
+	for marker := (Marker{}); marker.NotDone(); {
+		resp, err := containerURL.ListBlobs(ctx, marker, ListBlobsOptions{Prefix: prefix})
+		if err != nil {
+			listErr = err
+			break
+		}
+		marker = resp.NextMarker
+		for _, blob := range resp.Blobs.Blob {
+			select {
+			case blobs <- blob:
+			case <-ctx.Done():
+				listErr = ctx.Err()
+				break list
+			}
+		}
+	}
+	close(blobs)
+	wg.Wait()
+	sort.Strings(downloaded)
+	if listErr != nil {
+		return downloaded, listErr
+	}
+	if len(errs) > 0 {
+		return downloaded, errs
+	}
+	return downloaded, nil
+}
+
+// downloadFile downloads blob to its file under localDir unless o.SkipUnchanged is set and the file is up to date.
+func downloadFile(ctx context.Context, blobURL BlobURL, blob Blob, localDir string, o DownloadContainerToDirectoryOptions) (skipped bool, err error) {
+	filePath := filepath.Join(localDir, filepath.FromSlash(blob.Name))
+	if rel, err := filepath.Rel(localDir, filePath); err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return false, fmt.Errorf("blob name %q doesn't identify a file under %s", blob.Name, localDir)
+	}
+	if o.SkipUnchanged && blob.Properties.ContentLength != nil {
+		if info, err := os.Stat(filePath); err == nil && info.Mode().IsRegular() &&
+			info.Size() == *blob.Properties.ContentLength && info.ModTime().Equal(blob.Properties.LastModified) {
+			return true, nil
+		}
+	}
+	if err = os.MkdirAll(filepath.Dir(filePath), 0777); err != nil {
+		return false, err
+	}
+	fo := o.Download
+	fo.Progress = nil
+	if o.Progress != nil {
+		fo.Progress = func(bytesTransferred int64) { o.Progress(blob.Name, bytesTransferred) }
+	}
+	props, err := DownloadBlobToFile(ctx, blobURL, filePath, fo)
+	if err != nil {
+		return false, err
+	}
+	// Record the blob's Last-Modified time so that SkipUnchanged can recognize this file later
+	lastModified := props.LastModified()
+	return false, os.Chtimes(filePath, lastModified, lastModified)
+}
+
+// detectContentType returns the MIME type for name's extension or, if it is unknown, the type
+// sniffed from the start of the stream.
+func detectContentType(name string, stream io.ReaderAt, streamSize int64) string {
+	if contentType := mime.TypeByExtension(filepath.Ext(name)); contentType != "" {
+		return contentType
+	}
+	head := make([]byte, 512) // http.DetectContentType considers at most 512 bytes
+	if streamSize < int64(len(head)) {
+		head = head[:streamSize]
+	}
+	n, _ := stream.ReadAt(head, 0)
+	return http.DetectContentType(head[:n])
+}
+
+// PutBlocksFromReaderOptions identifies options used by the PutBlocksFromReader function.
+type PutBlocksFromReaderOptions struct {
+	// MaxBlockCount indicates the maximum number of blocks the stream may be split into (0=default of BlockBlobMaxBlocks).
+	// Raise it only when targeting a service version that allows more blocks per blob.
+	MaxBlockCount int64
+}
+
+// PutBlocksFromReader reads the stream in blockSize chunks and uploads them as uncommitted blocks with up to
+// parallelism PutBlock calls in flight. It returns the block IDs in the order the chunks were read; pass them to
+// PutBlockList to commit the blob. At most parallelism chunks are buffered at once so memory use is bounded
+// by parallelism*blockSize. If any PutBlock fails, reading stops and the first error is returned; if the stream
+// needs more than o.MaxBlockCount blocks, ErrTooManyBlocks is returned.
+func PutBlocksFromReader(ctx context.Context, blockBlobURL BlockBlobURL, stream io.Reader, blockSize int64, parallelism int,
+	o PutBlocksFromReaderOptions) ([]string, error) {
+	if blockSize <= 0 || blockSize > BlockBlobMaxPutBlockBytes {
+		panic(fmt.Sprintf("blockSize must be > 0 and <= %d", BlockBlobMaxPutBlockBytes))
+	}
+	if parallelism <= 0 {
+		panic("parallelism must be > 0")
+	}
+	if o.MaxBlockCount < 0 {
+		panic("MaxBlockCount must be >= 0")
+	}
+	if o.MaxBlockCount == 0 {
+		o.MaxBlockCount = BlockBlobMaxBlocks
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	buffers := make(chan []byte, parallelism) // A buffer is taken before reading a chunk and returned after its PutBlock
+	for i := 0; i < parallelism; i++ {
+		buffers <- make([]byte, blockSize)
+	}
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+		blockIDs []string
+	)
+	setErr := func(err error) { errOnce.Do(func() { firstErr = err; cancel() }) }
+
+	for done := false; !done; {
+		var buffer []byte
+		select {
+		case buffer = <-buffers:
+		case <-ctx.Done():
+			setErr(ctx.Err())
+		}
+		if buffer == nil {
+			break // A PutBlock failed or the caller's ctx is done
+		}
+		n, err := io.ReadFull(stream, buffer)
+		switch err {
+		case nil:
+		case io.EOF, io.ErrUnexpectedEOF:
+			done = true // This is the last (possibly empty) chunk
+		default:
+			setErr(err)
+		}
+		if n == 0 || ctx.Err() != nil { // ctx is canceled if a read or PutBlock failed
+			break
+		}
+		if int64(len(blockIDs)) == o.MaxBlockCount {
+			setErr(ErrTooManyBlocks)
+			break
+		}
+		// Block IDs are unique values to avoid issue if 2+ clients are uploading blocks at the same time
+		blockID := base64.StdEncoding.EncodeToString(newUUID().bytes())
+		blockIDs = append(blockIDs, blockID)
+
+		wg.Add(1)
+		go func(buffer []byte, n int) {
+			defer wg.Done()
+			if _, err := blockBlobURL.PutBlock(ctx, blockID, bytes.NewReader(buffer[:n]), LeaseAccessConditions{}); err != nil {
+				setErr(err)
+			}
+			buffers <- buffer
+		}(buffer, n)
+	}
+	wg.Wait()
+	if firstErr == nil {
+		firstErr = ctx.Err() // The caller's ctx may have stopped the uploads early
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return blockIDs, nil
+}
+
+// RetryableBodyOptions configures how NewRetryableBody buffers a non-seekable stream.
+type RetryableBodyOptions struct {
+	// MaxMemoryBytes is the maximum number of bytes buffered in memory (0=default of 4MB).
+	MaxMemoryBytes int64
+
+	// SpillToFile indicates whether a stream longer than MaxMemoryBytes is written to a temporary file.
+	// If false, NewRetryableBody returns an error for a stream longer than MaxMemoryBytes.
+	SpillToFile bool
+
+	// TempDir indicates the directory where the temporary file is created ("" means os.TempDir()).
+	TempDir string
+}
+
+func (o RetryableBodyOptions) defaults() RetryableBodyOptions {
+	if o.MaxMemoryBytes == 0 {
+		o.MaxMemoryBytes = 4 * 1024 * 1024 // 4MB
+	}
+	if o.MaxMemoryBytes < 0 {
+		panic("MaxMemoryBytes must be >= 0")
+	}
+	return o
+}
+
+// RetryableBody is a seekable copy of a non-seekable stream. Pass it wherever a request body
+// must be an io.ReadSeeker (PutBlock, AppendBlock, PutPages, etc.) so the retry policy can
+// replay it. Call Close when finished to release any temporary file.
+type RetryableBody struct {
+	io.ReadSeeker
+	file *os.File // nil if the stream was buffered in memory
+}
+
+// NewRetryableBody reads the stream to its end, buffering it in memory or, if it is longer than
+// o.MaxMemoryBytes and o.SpillToFile is true, in a temporary file.
+func NewRetryableBody(stream io.Reader, o RetryableBodyOptions) (*RetryableBody, error) {
+	o = o.defaults()
+
+	// Read 1 byte more than allowed so we can tell whether the stream fits in memory
+	buffer := &bytes.Buffer{}
+	if _, err := buffer.ReadFrom(io.LimitReader(stream, o.MaxMemoryBytes+1)); err != nil {
+		return nil, err
+	}
+	if int64(buffer.Len()) <= o.MaxMemoryBytes {
+		return &RetryableBody{ReadSeeker: bytes.NewReader(buffer.Bytes())}, nil
+	}
+	if !o.SpillToFile {
+		return nil, fmt.Errorf("the stream is longer than MaxMemoryBytes (%d) and SpillToFile is false", o.MaxMemoryBytes)
+	}
+
+	// Spill what we've read so far and the rest of the stream to a temporary file
+	file, err := ioutil.TempFile(o.TempDir, "azblob")
+	if err != nil {
+		return nil, err
+	}
+	body := &RetryableBody{ReadSeeker: file, file: file}
+	if _, err = io.Copy(file, io.MultiReader(buffer, stream)); err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		body.Close()
+		return nil, err
+	}
+	return body, nil
+}
+
+// Close releases the resources (if any) used to buffer the stream.
+func (b *RetryableBody) Close() error {
+	if b.file == nil {
+		return nil
+	}
+	err := b.file.Close()
+	if rerr := os.Remove(b.file.Name()); err == nil {
+		err = rerr
+	}
+	return err
+}
+
+// CopyBlobOptions identifies options used by the CopyBlob function.
+type CopyBlobOptions struct {
+	// Metadata indicates the metadata to be associated with the destination blob (nil=the source blob's metadata).
+	Metadata Metadata
+
+	// SourceAccessConditions indicates the access conditions for the source blob.
+	SourceAccessConditions BlobAccessConditions
+
+	// DestinationAccessConditions indicates the access conditions for the destination blob.
+	DestinationAccessConditions BlobAccessConditions
+
+	// Started is a function that is invoked with the copy's ID as soon as the copy starts (before CopyBlob waits for it).
+	// Persist the ID to resume waiting (with BlobURL.WaitForCopy) or to abort the copy (with BlobURL.AbortCopy) later,
+	// for example, after the process restarts. If another copy has replaced this one by then, WaitForCopy returns a
+	// *CopyFailedError naming both copies.
+	Started func(copyID string)
+
+	// WaitForCopyOptions configures how CopyBlob polls the destination blob's copy status.
+	WaitForCopyOptions WaitForCopyOptions
+}
+
+// CopyBlob copies the data at the source URL to the blob and waits for the copy to complete, returning the blob's ETag.
+// If ctx is done before the copy completes, CopyBlob aborts the copy (leaving a 0-length destination blob), waiting at
+// most 1 minute for the abort, and returns ctx's error. A copy that doesn't succeed returns a *CopyFailedError.
+// For more information, see https://docs.microsoft.com/rest/api/storageservices/copy-blob.
+func CopyBlob(ctx context.Context, blobURL BlobURL, source url.URL, o CopyBlobOptions) (ETag, error) {
+	resp, err := blobURL.StartCopy(ctx, source, o.Metadata, o.SourceAccessConditions, o.DestinationAccessConditions)
+	if err != nil {
+		return ETagNone, err
+	}
+	if o.Started != nil {
+		o.Started(resp.CopyID())
+	}
+	if resp.CopyStatus() == CopyStatusSuccess {
+		return resp.ETag(), nil // Small copies complete synchronously
+	}
+	etag, err := blobURL.WaitForCopy(ctx, resp.CopyID(), o.WaitForCopyOptions)
+	if err != nil && ctx.Err() != nil {
+		// ctx is done so abort the copy with a (bounded) context of its own
+		abortCtx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		if _, abortErr := blobURL.AbortCopy(abortCtx, resp.CopyID(), o.DestinationAccessConditions.LeaseAccessConditions); abortErr != nil {
+			return ETagNone, abortErr
+		}
+		return ETagNone, ctx.Err()
+	}
+	return etag, err
 }
 
 // DownloadStreamOptions is used to configure a call to NewDownloadBlobToStream to download a large stream with intelligent retries.
@@ -76,6 +638,22 @@ type DownloadStreamOptions struct {
 
 	// AccessConditions indicates the BlobAccessConditions to use when accessing the blob.
 	AccessConditions BlobAccessConditions
+
+	// DecompressResponse indicates whether a blob whose Content-Encoding is gzip or deflate is decompressed as it is read.
+	// Retries resume the compressed stream where it left off so the decompressor is unaffected by them.
+	// NOTE: A Range applies to the compressed bytes and a compressed stream can only be decompressed from its beginning
+	// so, for a compressed blob, Range must be the entire blob; otherwise, Read returns ErrDecompressRange.
+	DecompressResponse bool
+}
+
+// ErrDecompressRange is returned when DownloadStreamOptions.DecompressResponse is combined with a Range that isn't
+// the entire blob and the blob is compressed.
+var ErrDecompressRange = errors.New("a compressed blob can only be decompressed when its entire contents are downloaded; " +
+	"the Range applies to the compressed bytes")
+
+// isEntireBlob returns true if r starts at the beginning of a blob and has no end.
+func (r BlobRange) isEntireBlob() bool {
+	return r.Offset == 0 && r.Count == CountToEnd
 }
 
 type retryStream struct {
@@ -89,7 +667,15 @@ type retryStream struct {
 // When network errors occur, the retry stream internally issues new HTTP GET requests for
 // the remaining range of the blob's contents. The GetBlob argument identifies the function
 // to invoke when the GetRetryStream needs to make an HTTP GET request as Read methods are called.
-// The callback can wrap the response body (with progress reporting, for example) before returning.
+// The callback can wrap the response body (with progress reporting, for example) before returning, and it
+// needn't be a BlobURL's GetBlob: to test retry behavior in isolation, pass a BlobURL whose pipeline ends in a
+// fake sender that fails mid-stream; to re-fetch differently (from a freshly signed URL, for example), pass a
+// func that calls GetBlob on the BlobURL of your choice.
+// After the first GET, every GET sends If-Match with the blob's ETag; if the blob is modified mid-download,
+// Read returns a StorageError with status 412 (Precondition Failed) rather than mixing data from 2 versions.
+// If AccessConditions' IfNoneMatch or IfModifiedSince cause the service to return 304 (Not Modified), Read returns a
+// StorageError with that status since there's no content to read.
+// NOTE: The Blob service doesn't support If-Range so If-Match is the way to detect a changed blob.
 func NewDownloadStream(ctx context.Context,
 	getBlob func(ctx context.Context, blobRange BlobRange, ac BlobAccessConditions, rangeGetContentMD5 bool) (*GetResponse, error),
 	o DownloadStreamOptions) io.ReadCloser {
@@ -98,7 +684,24 @@ func NewDownloadStream(ctx context.Context,
 	if getBlob == nil {
 		panic("getBlob must not be nil")
 	}
-	return &retryStream{ctx: ctx, getBlob: getBlob, o: o, response: nil}
+	s := &retryStream{ctx: ctx, getBlob: getBlob, o: o, response: nil}
+	if o.DecompressResponse {
+		return &decompressingStream{s: s, entireBlob: o.Range.isEntireBlob()}
+	}
+	return s
+}
+
+// GetBlobWithNewURL returns a getBlob func for NewDownloadStream (or NewSeekableDownloadStream) that calls getNewURL
+// before every GET and reads from the returned URL using the specified pipeline. Use it when the blob's URL has a
+// short-lived SAS: getNewURL can return a freshly signed URL so a retry after the original SAS expires doesn't fail
+// with 403 (Forbidden). Every URL must refer to the same blob.
+func GetBlobWithNewURL(getNewURL func() url.URL, p pipeline.Pipeline) func(ctx context.Context, blobRange BlobRange, ac BlobAccessConditions, rangeGetContentMD5 bool) (*GetResponse, error) {
+	if getNewURL == nil {
+		panic("getNewURL must not be nil")
+	}
+	return func(ctx context.Context, blobRange BlobRange, ac BlobAccessConditions, rangeGetContentMD5 bool) (*GetResponse, error) {
+		return NewBlobURL(getNewURL(), p).GetBlob(ctx, blobRange, ac, rangeGetContentMD5)
+	}
 }
 
 func (s *retryStream) Read(p []byte) (n int, err error) {
@@ -128,6 +731,10 @@ func (s *retryStream) Read(p []byte) (n int, err error) {
 		if err != nil {
 			return 0, err
 		}
+		if response.NotModified() {
+			// A 304 has no content to stream; reporting EOF would look like an empty blob
+			return 0, NewResponseError(nil, response.Response(), response.Response().Status)
+		}
 		// Successful GET; this is the network stream we'll read from
 		s.response = response.Response()
 
@@ -144,3 +751,393 @@ func (s *retryStream) Close() error {
 	}
 	return nil
 }
+
+// SeekableDownloadStreamOptions is used to configure a call to NewSeekableDownloadStream.
+type SeekableDownloadStreamOptions struct {
+	// AccessConditions indicates the BlobAccessConditions to use when accessing the blob.
+	AccessConditions BlobAccessConditions
+
+	// ReadAheadSize indicates the number of bytes fetched by each GET (0=each GET requests the rest of the blob).
+	// Reads and seeks within the most recently fetched window are served from memory without another GET,
+	// which saves requests when reading in small increments with frequent seeks.
+	ReadAheadSize int64
+}
+
+// SeekableDownloadStream is an io.ReadSeeker over a blob's contents; it is created by NewSeekableDownloadStream.
+type SeekableDownloadStream struct {
+	ctx     context.Context
+	getBlob func(ctx context.Context, blobRange BlobRange, ac BlobAccessConditions, rangeGetContentMD5 bool) (*GetResponse, error)
+	size    int64
+	offset  int64
+	ac      BlobAccessConditions
+	stream  *retryStream // nil until Read needs a GET from offset
+
+	readAheadSize int64
+	buffer        []byte // The window most recently fetched when readAheadSize > 0
+	bufferOffset  int64
+}
+
+// NewSeekableDownloadStream creates a stream over a blob of blobSize bytes that supports random access. Get blobSize
+// from GetPropertiesAndMetadata or, if the blob was already read with GetBlob, that GetResponse's BlobContentLength.
+// Seek doesn't send a request; the next Read issues a GET for the rest of the blob from the new offset and retries it
+// the same way as NewDownloadStream. Every GET after the first sends If-Match with the blob's ETag so all reads see
+// the same version of the blob.
+func NewSeekableDownloadStream(ctx context.Context,
+	getBlob func(ctx context.Context, blobRange BlobRange, ac BlobAccessConditions, rangeGetContentMD5 bool) (*GetResponse, error),
+	blobSize int64, o SeekableDownloadStreamOptions) *SeekableDownloadStream {
+	if getBlob == nil {
+		panic("getBlob must not be nil")
+	}
+	if blobSize < 0 {
+		panic("blobSize must be >= 0")
+	}
+	if o.ReadAheadSize < 0 {
+		panic("ReadAheadSize must be >= 0")
+	}
+	return &SeekableDownloadStream{ctx: ctx, getBlob: getBlob, size: blobSize, ac: o.AccessConditions, readAheadSize: o.ReadAheadSize}
+}
+
+// Read reads up to len(p) bytes from the blob starting at the current offset.
+func (s *SeekableDownloadStream) Read(p []byte) (int, error) {
+	if s.offset >= s.size {
+		return 0, io.EOF
+	}
+	if s.readAheadSize > 0 {
+		return s.readBuffered(p)
+	}
+	if s.stream == nil {
+		s.stream = &retryStream{ctx: s.ctx, getBlob: s.getBlob,
+			o: DownloadStreamOptions{Range: BlobRange{Offset: s.offset, Count: s.size - s.offset}, AccessConditions: s.ac}}
+	}
+	n, err := s.stream.Read(p)
+	s.offset += int64(n)
+	s.ac.IfMatch = s.stream.o.AccessConditions.IfMatch // Later GETs must read the same version of the blob
+	if err == io.EOF && s.offset < s.size {
+		err = io.ErrUnexpectedEOF // The blob is shorter than blobSize
+	}
+	return n, err
+}
+
+// readBuffered copies from the buffered window, first fetching the window starting at offset if offset is outside it.
+func (s *SeekableDownloadStream) readBuffered(p []byte) (int, error) {
+	if s.offset < s.bufferOffset || s.offset >= s.bufferOffset+int64(len(s.buffer)) {
+		count := s.readAheadSize
+		if s.offset+count > s.size {
+			count = s.size - s.offset
+		}
+		if s.buffer == nil {
+			s.buffer = make([]byte, 0, s.readAheadSize)
+		}
+		window := &retryStream{ctx: s.ctx, getBlob: s.getBlob,
+			o: DownloadStreamOptions{Range: BlobRange{Offset: s.offset, Count: count}, AccessConditions: s.ac}}
+		_, err := io.ReadFull(window, s.buffer[:count])
+		window.Close()
+		s.ac.IfMatch = window.o.AccessConditions.IfMatch // Later GETs must read the same version of the blob
+		if err != nil {
+			s.buffer = s.buffer[:0] // The window is incomplete
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF // The blob is shorter than blobSize
+			}
+			return 0, err
+		}
+		s.buffer, s.bufferOffset = s.buffer[:count], s.offset
+	}
+	n := copy(p, s.buffer[s.offset-s.bufferOffset:])
+	s.offset += int64(n)
+	return n, nil
+}
+
+// Seek sets the offset for the next Read as described by io.Seeker. Seeking beyond the end of the blob is allowed;
+// Read then returns io.EOF.
+func (s *SeekableDownloadStream) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += s.offset
+	case io.SeekEnd:
+		offset += s.size
+	default:
+		return s.offset, fmt.Errorf("invalid whence: %d", whence)
+	}
+	if offset < 0 {
+		return s.offset, errors.New("negative offset")
+	}
+	if offset != s.offset {
+		s.Close() // The next Read needs a GET starting at the new offset
+		s.offset = offset
+	}
+	return offset, nil
+}
+
+// Close closes the response body of the current GET, if any. The stream may still be read after Close.
+func (s *SeekableDownloadStream) Close() error {
+	if s.stream == nil {
+		return nil
+	}
+	err := s.stream.Close()
+	s.stream = nil
+	return err
+}
+
+// DownloadBlobToFileOptions identifies options used by the DownloadBlobToFile function.
+type DownloadBlobToFileOptions struct {
+	// BlockSize specifies the size of each ranged GET (0=default of 4MB).
+	BlockSize int64
+
+	// Parallelism indicates the maximum number of ranges downloaded in parallel (0=default of 5).
+	Parallelism uint16
+
+	// Progress is a function that is invoked periodically as bytes are written to the file. Although ranges are
+	// downloaded in parallel, calls to it are serialized and report a growing total.
+	Progress pipeline.ProgressReceiver
+
+	// AccessConditions indicates the access conditions for the blob. All ranges are downloaded from
+	// the same version of the blob (If-Match is set to the blob's ETag).
+	AccessConditions BlobAccessConditions
+
+	// KeepPartial indicates whether the ".part" file is kept (instead of removed) if the download fails.
+	KeepPartial bool
+
+	// VerifyChunkMD5 indicates whether the service returns an MD5 for each range and each range is verified before it
+	// is written to the file. A mismatch aborts the download with a *ChecksumMismatchError identifying the range.
+	// BlockSize must be <= BlobMaxRangeGetContentMD5Bytes.
+	VerifyChunkMD5 bool
+}
+
+func (o DownloadBlobToFileOptions) defaults() DownloadBlobToFileOptions {
+	if o.BlockSize < 0 {
+		panic("BlockSize must be >= 0")
+	}
+	if o.BlockSize == 0 {
+		o.BlockSize = 4 * 1024 * 1024 // 4MB
+	}
+	if o.Parallelism == 0 {
+		o.Parallelism = 5
+	}
+	if o.VerifyChunkMD5 && o.BlockSize > BlobMaxRangeGetContentMD5Bytes {
+		panic(fmt.Sprintf("BlockSize must be <= %d when VerifyChunkMD5 is true", BlobMaxRangeGetContentMD5Bytes))
+	}
+	return o
+}
+
+// DownloadBlobToFile downloads a blob to the file at filePath. The blob is downloaded into filePath+".part" in the same
+// directory which is renamed to filePath only after the whole blob has been downloaded and, if the blob has a Content-MD5,
+// verified (a mismatch returns a *ChecksumMismatchError). So, a failed download never leaves a partial file at filePath.
+// The blob's size comes from the first range's Content-Range (see GetResponse.BlobContentLength) so no separate
+// GetProperties request is sent. The first range's response is returned for the blob's properties and metadata;
+// its body has already been read.
+func DownloadBlobToFile(ctx context.Context, blobURL BlobURL, filePath string, o DownloadBlobToFileOptions) (*GetResponse, error) {
+	o = o.defaults()
+	getBlob := blobURL.GetBlob
+	if o.VerifyChunkMD5 {
+		getBlob = func(ctx context.Context, blobRange BlobRange, ac BlobAccessConditions, _ bool) (*GetResponse, error) {
+			return blobURL.GetBlob(ctx, blobRange, ac, true)
+		}
+	}
+	first, err := getBlob(ctx, BlobRange{Offset: 0, Count: o.BlockSize}, o.AccessConditions, o.VerifyChunkMD5)
+	if serr, ok := err.(StorageError); ok && serr.ServiceCode() == ServiceCodeInvalidRange {
+		first, err = blobURL.GetBlob(ctx, BlobRange{}, o.AccessConditions, false) // The blob is empty so it has no first range
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer first.Response().Body.Close()
+	if first.NotModified() {
+		return nil, NewResponseError(nil, first.Response(), first.Response().Status)
+	}
+	blobSize := first.BlobContentLength()
+	if blobSize < 0 {
+		return nil, fmt.Errorf("the blob's size can't be determined from Content-Range %q", first.ContentRange())
+	}
+	ac := o.AccessConditions
+	ac.IfMatch = first.ETag() // Ensure that all ranges come from the same version of the blob
+
+	partPath := filePath + ".part"
+	file, err := os.OpenFile(partPath, os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0666)
+	if err != nil {
+		return nil, err
+	}
+	fail := func(err error) (*GetResponse, error) {
+		file.Close()
+		if !o.KeepPartial {
+			os.Remove(partPath)
+		}
+		return nil, err
+	}
+	if err = file.Truncate(blobSize); err != nil {
+		return fail(err)
+	}
+	if err = downloadBlobToWriterAt(ctx, getBlob, first.Response(), blobSize, file, ac, o); err != nil {
+		return fail(err)
+	}
+
+	expected := first.BlobContentMD5() // A ranged GET returns the whole blob's MD5 in x-ms-blob-content-md5
+	if first.ContentRange() == "" {
+		expected = first.ContentMD5()
+	}
+	if expected != ([md5.Size]byte{}) {
+		h := md5.New()
+		if _, err = io.Copy(h, io.NewSectionReader(file, 0, blobSize)); err != nil {
+			return fail(err)
+		}
+		var actual [md5.Size]byte
+		copy(actual[:], h.Sum(nil))
+		if actual != expected {
+			return fail(&ChecksumMismatchError{Offset: 0, Count: blobSize, Expected: expected, Actual: actual})
+		}
+	}
+	if err = file.Close(); err != nil {
+		return fail(err)
+	}
+	if err = os.Rename(partPath, filePath); err != nil {
+		os.Remove(partPath)
+		return nil, err
+	}
+	return first, nil
+}
+
+// downloadBlobToWriterAt downloads blobSize bytes of the blob in o.BlockSize ranges, o.Parallelism at a time.
+// The first range is read from first, the response to a GET of that range. It returns the first error encountered
+// and cancels any remaining downloads.
+func downloadBlobToWriterAt(ctx context.Context,
+	getBlob func(ctx context.Context, blobRange BlobRange, ac BlobAccessConditions, rangeGetContentMD5 bool) (*GetResponse, error),
+	first *http.Response, blobSize int64, w io.WriterAt, ac BlobAccessConditions, o DownloadBlobToFileOptions) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	offsets := make(chan int64)
+	go func() {
+		defer close(offsets)
+		for offset := int64(0); offset < blobSize; offset += o.BlockSize {
+			select {
+			case offsets <- offset:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var (
+		wg               sync.WaitGroup
+		errOnce          sync.Once
+		firstErr         error
+		progressLock     sync.Mutex
+		bytesTransferred int64
+	)
+	for g := uint16(0); g < o.Parallelism; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for offset := range offsets {
+				count := o.BlockSize
+				if offset+count > blobSize {
+					count = blobSize - offset
+				}
+				body := &retryStream{ctx: ctx, getBlob: getBlob,
+					o: DownloadStreamOptions{Range: BlobRange{Offset: offset, Count: count}, AccessConditions: ac}}
+				if offset == 0 {
+					body.response = first // Continue reading the GET that returned the blob's size
+				}
+				var err error
+				if o.VerifyChunkMD5 {
+					var chunk []byte
+					if chunk, err = ioutil.ReadAll(body); err == nil { // The range is verified when its body is read to EOF
+						_, err = w.WriteAt(chunk, offset)
+					}
+				} else {
+					_, err = io.Copy(&offsetWriter{w: w, offset: offset}, body)
+				}
+				body.Close()
+				if err != nil {
+					errOnce.Do(func() { firstErr = err; cancel() })
+					return
+				}
+				if o.Progress != nil {
+					progressLock.Lock()
+					bytesTransferred += count
+					o.Progress(bytesTransferred)
+					progressLock.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if firstErr == nil {
+		firstErr = ctx.Err() // The caller's ctx may have stopped the downloads early
+	}
+	return firstErr
+}
+
+// offsetWriter adapts an io.WriterAt to an io.Writer that writes sequentially starting at offset.
+type offsetWriter struct {
+	w      io.WriterAt
+	offset int64
+}
+
+func (ow *offsetWriter) Write(p []byte) (int, error) {
+	n, err := ow.w.WriteAt(p, ow.offset)
+	ow.offset += int64(n)
+	return n, err
+}
+
+// decompressingStream decompresses a retryStream according to the blob's Content-Encoding.
+type decompressingStream struct {
+	s          *retryStream
+	entireBlob bool
+	r          io.Reader // nil until the first Read gets the response's Content-Encoding
+	err        error     // Set if the response can't be decompressed; every later Read returns it
+}
+
+func (d *decompressingStream) Read(p []byte) (int, error) {
+	if d.err != nil {
+		return 0, d.err
+	}
+	if d.r == nil {
+		if _, err := d.s.Read(p[:0]); err != nil && err != io.EOF { // Get a response without consuming any data
+			return 0, err
+		}
+		r := io.Reader(d.s) // Not compressed (or an empty blob); read the data as is
+		if d.s.response != nil {
+			encoding := strings.ToLower(d.s.response.Header.Get("Content-Encoding"))
+			if (encoding == "gzip" || encoding == "deflate") && !d.entireBlob {
+				d.err = ErrDecompressRange
+				return 0, d.err
+			}
+			switch encoding {
+			case "gzip":
+				gz, err := gzip.NewReader(d.s)
+				if err != nil {
+					d.err = err
+					return 0, err
+				}
+				r = gz
+			case "deflate":
+				zr, err := newDeflateReader(d.s)
+				if err != nil {
+					d.err = err
+					return 0, err
+				}
+				r = zr
+			}
+		}
+		d.r = r
+	}
+	return d.r.Read(p)
+}
+
+// newDeflateReader returns a reader that decompresses r's "deflate" content. HTTP's deflate is the zlib format
+// (RFC 1950) but some clients send raw deflate data (RFC 1951) so a stream without a zlib header is read as raw.
+func newDeflateReader(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	if h, _ := br.Peek(2); len(h) == 2 && h[0]&0x0f == 8 && (uint16(h[0])<<8|uint16(h[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
+
+func (d *decompressingStream) Close() error {
+	if c, ok := d.r.(io.Closer); ok && d.r != io.Reader(d.s) {
+		c.Close() // Decompressors don't close their source
+	}
+	return d.s.Close()
+}
@@ -31,7 +31,7 @@ func NewBlobURLParts(u url.URL) BlobURLParts {
 		Host:   u.Host,
 	}
 
-	// Find the container & blob names (if any)
+	// Find the container & blob names (if any). u.Path is unescaped so these are the actual names; URL escapes them again.
 	if u.Path != "" {
 		path := u.Path
 		if path[0] == '/' {
@@ -52,10 +52,10 @@ func NewBlobURLParts(u url.URL) BlobURLParts {
 	paramsMap := u.Query()
 
 	up.Snapshot = time.Time{} // Assume no snapshot
-	if snapshotStr, ok := caseInsensitiveValues(paramsMap).Get("snapshot"); ok {
+	if key, snapshotStr, ok := caseInsensitiveValues(paramsMap).Get("snapshot"); ok {
 		up.Snapshot, _ = time.Parse(snapshotTimeFormat, snapshotStr[0])
 		// If we recognized the query parameter, remove it from the map
-		delete(paramsMap, "snapshot")
+		delete(paramsMap, key)
 	}
 	up.SAS = NewSASQueryParameters(paramsMap, true)
 	up.UnparsedParams = paramsMap.Encode()
@@ -63,14 +63,15 @@ func NewBlobURLParts(u url.URL) BlobURLParts {
 }
 
 type caseInsensitiveValues url.Values // map[string][]string
-func (v caseInsensitiveValues) Get(key string) ([]string, bool) {
-	key = strings.ToLower(key)
-	for key, value := range v {
-		if strings.ToLower(key) == key {
-			return value, true
+
+// Get returns the values of the parameter whose name equals key ignoring case, and that name as it appears in v.
+func (v caseInsensitiveValues) Get(key string) (string, []string, bool) {
+	for k, value := range v {
+		if strings.EqualFold(k, key) {
+			return k, value, true
 		}
 	}
-	return []string{}, false
+	return "", []string{}, false
 }
 
 // URL returns a URL object whose fields are initialized from the BlobURLParts fields. The URL's RawQuery
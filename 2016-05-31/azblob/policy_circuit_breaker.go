@@ -0,0 +1,200 @@
+package azblob
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+)
+
+// ErrCircuitOpen is returned (without contacting the service) while the circuit breaker is open.
+// It is not a temporary error so the retry policy doesn't retry it.
+var ErrCircuitOpen = errors.New("circuit breaker is open; the request was not sent")
+
+// CircuitBreakerState indicates whether a circuit breaker is letting requests through. See the CircuitBreaker* constants.
+type CircuitBreakerState int32
+
+const (
+	// CircuitBreakerClosed indicates that requests are sent normally.
+	CircuitBreakerClosed CircuitBreakerState = 0
+
+	// CircuitBreakerOpen indicates that requests fail fast with ErrCircuitOpen.
+	CircuitBreakerOpen CircuitBreakerState = 1
+
+	// CircuitBreakerHalfOpen indicates that a single probe request is being sent to see whether the service has recovered.
+	CircuitBreakerHalfOpen CircuitBreakerState = 2
+)
+
+// String returns the state's name.
+func (s CircuitBreakerState) String() string {
+	switch s {
+	case CircuitBreakerClosed:
+		return "Closed"
+	case CircuitBreakerOpen:
+		return "Open"
+	case CircuitBreakerHalfOpen:
+		return "HalfOpen"
+	}
+	return "Unknown"
+}
+
+// CircuitBreakerOptions configures the circuit breaker policy's behavior.
+type CircuitBreakerOptions struct {
+	// FailureThreshold indicates how many consecutive failures trip the circuit breaker open (0=default of 5).
+	FailureThreshold int32
+
+	// Cooldown indicates how long the circuit breaker stays open before it lets a probe request through (0=default of 30 seconds).
+	Cooldown time.Duration
+}
+
+func (o CircuitBreakerOptions) defaults() CircuitBreakerOptions {
+	if o.FailureThreshold < 0 || o.Cooldown < 0 {
+		panic("FailureThreshold and Cooldown must be >= 0")
+	}
+	if o.FailureThreshold == 0 {
+		o.FailureThreshold = 5
+	}
+	if o.Cooldown == 0 {
+		o.Cooldown = 30 * time.Second
+	}
+	return o
+}
+
+// CircuitBreakerMetrics reports what a circuit breaker has observed since it was created.
+type CircuitBreakerMetrics struct {
+	State               CircuitBreakerState
+	Requests            int64 // Requests sent to the service
+	Failures            int64 // Sent requests that failed
+	Rejected            int64 // Requests failed fast with ErrCircuitOpen
+	Trips               int64 // Number of times the circuit breaker opened
+	ConsecutiveFailures int32
+}
+
+// CircuitBreakerPolicyFactory is a pipeline.Factory whose policies share a single circuit breaker.
+// Place it after the retry policy's factory so that every try is counted and, once open, retries fail fast;
+// NewPipeline does this for PipelineOptions.CircuitBreaker.
+type CircuitBreakerPolicyFactory struct {
+	o        CircuitBreakerOptions
+	lock     sync.Mutex
+	metrics  CircuitBreakerMetrics
+	openedAt time.Time
+	probe    uint64 // Identifies the outstanding half-open probe request (0=none)
+	probes   uint64 // Number of probes sent; used to give each probe its own ID
+}
+
+// NewCircuitBreakerPolicyFactory creates a CircuitBreakerPolicyFactory object configured using the specified options.
+func NewCircuitBreakerPolicyFactory(o CircuitBreakerOptions) *CircuitBreakerPolicyFactory {
+	return &CircuitBreakerPolicyFactory{o: o.defaults()}
+}
+
+// New creates a CircuitBreakerPolicy object.
+func (f *CircuitBreakerPolicyFactory) New(node pipeline.Node) pipeline.Policy {
+	return &circuitBreakerPolicy{node: node, factory: f}
+}
+
+// State returns the circuit breaker's current state.
+func (f *CircuitBreakerPolicyFactory) State() CircuitBreakerState {
+	return f.Metrics().State
+}
+
+// Metrics returns a snapshot of the circuit breaker's metrics.
+func (f *CircuitBreakerPolicyFactory) Metrics() CircuitBreakerMetrics {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.metrics.State == CircuitBreakerOpen && pkgClock.Now().Sub(f.openedAt) >= f.o.Cooldown {
+		f.metrics.State = CircuitBreakerHalfOpen
+	}
+	return f.metrics
+}
+
+// allow returns true if a request may be sent to the service. If the request is the half-open probe, probe
+// identifies it (otherwise probe is 0) and must be passed to record.
+func (f *CircuitBreakerPolicyFactory) allow() (probe uint64, ok bool) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	switch f.metrics.State {
+	case CircuitBreakerOpen:
+		if pkgClock.Now().Sub(f.openedAt) < f.o.Cooldown {
+			break // Still cooling down; fail fast
+		}
+		f.metrics.State = CircuitBreakerHalfOpen
+		fallthrough
+	case CircuitBreakerHalfOpen:
+		if f.probe != 0 {
+			break // Only 1 probe at a time; fail fast
+		}
+		f.probes++
+		f.probe = f.probes
+		f.metrics.Requests++
+		return f.probe, true
+	default:
+		f.metrics.Requests++
+		return 0, true
+	}
+	f.metrics.Rejected++
+	return 0, false
+}
+
+// record updates the circuit breaker's state with the outcome of a request that was sent. probe is the value allow
+// returned for the request; a request sent before the circuit breaker opened may finish while it is half-open and
+// must not be mistaken for the probe.
+func (f *CircuitBreakerPolicyFactory) record(probe uint64, failed bool) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	wasProbe := probe != 0 && probe == f.probe
+	if wasProbe {
+		f.probe = 0
+	}
+	if !failed {
+		f.metrics.ConsecutiveFailures = 0
+		if wasProbe { // The service recovered
+			f.metrics.State = CircuitBreakerClosed
+		}
+		return
+	}
+	f.metrics.Failures++
+	f.metrics.ConsecutiveFailures++
+	if wasProbe || (f.metrics.State == CircuitBreakerClosed && f.metrics.ConsecutiveFailures >= f.o.FailureThreshold) {
+		f.metrics.State = CircuitBreakerOpen
+		f.metrics.Trips++
+		f.openedAt = pkgClock.Now()
+	}
+}
+
+type circuitBreakerPolicy struct {
+	node    pipeline.Node
+	factory *CircuitBreakerPolicyFactory
+}
+
+func (p *circuitBreakerPolicy) Do(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+	probe, ok := p.factory.allow()
+	if !ok {
+		return nil, ErrCircuitOpen
+	}
+	response, err := p.node.Do(ctx, request)
+	p.factory.record(probe, isServiceFailure(response, err))
+	return response, err
+}
+
+// isServiceFailure returns true if the response/error indicates that the service (or the network to it) is unhealthy.
+// Errors like 404 or 412 indicate a healthy service and are not failures.
+func isServiceFailure(response pipeline.Response, err error) bool {
+	if err != nil {
+		if nerr, ok := err.(net.Error); ok && (nerr.Temporary() || nerr.Timeout()) {
+			return true
+		}
+	}
+	if response != nil && response.Response() != nil {
+		switch sc := response.Response().StatusCode; {
+		case sc == http.StatusNotImplemented || sc == http.StatusHTTPVersionNotSupported:
+			return false
+		case sc >= http.StatusInternalServerError:
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+package azblob
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+)
+
+// ConcurrencyLimiter is a pipeline.Factory whose policies share a limit on the number of requests in flight.
+// A request waits (until its context is done) for a slot before it is sent and holds the slot until its
+// response headers arrive. Put it before the retry policy (as NewPipeline does) so the wait doesn't count
+// against a try's TryTimeout; the request then holds its slot across its retries and their delays. After the
+// retry policy, each try waits for a slot of its own and the wait eats into the try's timeout. Share one
+// ConcurrencyLimiter across pipelines to limit them all together.
+type ConcurrencyLimiter struct {
+	slots    chan struct{}
+	inFlight int32
+}
+
+// NewConcurrencyLimiter creates a ConcurrencyLimiter that allows up to maxConcurrentRequests requests in flight.
+func NewConcurrencyLimiter(maxConcurrentRequests int) *ConcurrencyLimiter {
+	if maxConcurrentRequests <= 0 {
+		panic("maxConcurrentRequests must be > 0")
+	}
+	return &ConcurrencyLimiter{slots: make(chan struct{}, maxConcurrentRequests)}
+}
+
+// InFlight returns the number of requests currently in flight.
+func (l *ConcurrencyLimiter) InFlight() int {
+	return int(atomic.LoadInt32(&l.inFlight))
+}
+
+// New creates a ConcurrencyLimitPolicy object.
+func (l *ConcurrencyLimiter) New(node pipeline.Node) pipeline.Policy {
+	return &concurrencyLimitPolicy{node: node, limiter: l}
+}
+
+type concurrencyLimitPolicy struct {
+	node    pipeline.Node
+	limiter *ConcurrencyLimiter
+}
+
+func (p *concurrencyLimitPolicy) Do(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+	select {
+	case p.limiter.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	atomic.AddInt32(&p.limiter.inFlight, 1)
+	defer func() {
+		atomic.AddInt32(&p.limiter.inFlight, -1)
+		<-p.limiter.slots
+	}()
+	return p.node.Do(ctx, request)
+}
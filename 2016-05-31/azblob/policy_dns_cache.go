@@ -0,0 +1,123 @@
+package azblob
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+)
+
+// DNSCacheOptions configures the DNS-caching HTTP sender's behavior.
+type DNSCacheOptions struct {
+	// TTL indicates how long a host's resolved addresses are used before they must be resolved again (0=default of 1 minute).
+	// Once half the TTL has elapsed, the addresses are refreshed in the background so callers rarely wait on the resolver.
+	// NOTE: Go's resolver doesn't report the records' own TTL so you should not set this higher than the DNS zone's TTL.
+	TTL time.Duration
+
+	// Resolver indicates the resolver used to look up hosts (nil=net.DefaultResolver).
+	Resolver DNSResolver
+}
+
+// DNSResolver looks up the addresses of a host; *net.Resolver implements it.
+type DNSResolver interface {
+	LookupHost(ctx context.Context, host string) (addrs []string, err error)
+}
+
+func (o DNSCacheOptions) defaults() DNSCacheOptions {
+	if o.TTL < 0 {
+		panic("TTL must be >= 0")
+	}
+	if o.TTL == 0 {
+		o.TTL = time.Minute
+	}
+	if o.Resolver == nil {
+		o.Resolver = net.DefaultResolver
+	}
+	return o
+}
+
+// NewDNSCacheHTTPSenderFactory creates a pipeline.Factory that sends HTTP requests over connections whose
+// host names are resolved via a cache. Pass it as PipelineOptions.HTTPSender. Connections are spread
+// round-robin across all of a host's A/AAAA records; if dialing one address fails, the next one is tried.
+func NewDNSCacheHTTPSenderFactory(o DNSCacheOptions) pipeline.Factory {
+	cache := &dnsCache{o: o.defaults(), entries: map[string]*dnsCacheEntry{}}
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	return newHTTPClientSenderFactory(cache.dialContext(dialer.DialContext))
+}
+
+type dnsCacheEntry struct {
+	addrs      []string
+	resolved   time.Time
+	refreshing int32 // 1 while a background refresh is in progress
+	next       uint32
+}
+
+type dnsCache struct {
+	o       DNSCacheOptions
+	lock    sync.Mutex
+	entries map[string]*dnsCacheEntry
+}
+
+// lookup returns the host's addresses, resolving them if they aren't cached or their TTL has expired.
+func (c *dnsCache) lookup(ctx context.Context, host string) (*dnsCacheEntry, error) {
+	c.lock.Lock()
+	e, ok := c.entries[host]
+	c.lock.Unlock()
+
+	if ok {
+		age := pkgClock.Now().Sub(e.resolved)
+		if age < c.o.TTL {
+			if age >= c.o.TTL/2 && atomic.CompareAndSwapInt32(&e.refreshing, 0, 1) {
+				go c.resolve(context.Background(), host) // Refresh before the entry expires
+			}
+			return e, nil
+		}
+	}
+	return c.resolve(ctx, host)
+}
+
+func (c *dnsCache) resolve(ctx context.Context, host string) (*dnsCacheEntry, error) {
+	addrs, err := c.o.Resolver.LookupHost(ctx, host)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if err != nil {
+		if e, ok := c.entries[host]; ok {
+			atomic.StoreInt32(&e.refreshing, 0) // Let a later lookup retry the refresh
+		}
+		return nil, err
+	}
+	e := &dnsCacheEntry{addrs: addrs, resolved: pkgClock.Now()}
+	c.entries[host] = e
+	return e, nil
+}
+
+// dialContext returns a DialContext function that resolves the address' host via the cache and connects with dial.
+func (c *dnsCache) dialContext(dial func(ctx context.Context, network, address string) (net.Conn, error)) func(ctx context.Context, network, address string) (net.Conn, error) {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+		if net.ParseIP(host) != nil {
+			return dial(ctx, network, address) // Nothing to resolve
+		}
+		e, err := c.lookup(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		// Start with the next address (round-robin) and fall back to the others if dialing fails
+		// (reduce the counter before converting it; on 32-bit platforms, int(counter) goes negative past MaxInt32)
+		start := int(atomic.AddUint32(&e.next, 1) % uint32(len(e.addrs)))
+		for i := range e.addrs {
+			addr := e.addrs[(start+i)%len(e.addrs)]
+			var conn net.Conn
+			if conn, err = dial(ctx, network, net.JoinHostPort(addr, port)); err == nil {
+				return conn, nil
+			}
+		}
+		return nil, err
+	}
+}
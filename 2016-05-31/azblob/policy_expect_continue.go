@@ -0,0 +1,56 @@
+package azblob
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+)
+
+// ExpectContinueOptions configures the Expect-100-continue policy's behavior.
+type ExpectContinueOptions struct {
+	// Enabled indicates whether PUT/POST requests carry an "Expect: 100-continue" header so that the
+	// service can reject a request (for example, with 403 or 409) before its body is sent.
+	// NOTE: The HTTP sender only waits for the service's interim response if its Transport has
+	// a non-zero ExpectContinueTimeout; http.DefaultTransport uses 1 second.
+	Enabled bool
+
+	// MinimumBodyBytes indicates the smallest request body that gets the header (0=default of 1MB).
+	// Small bodies aren't worth the extra round trip.
+	MinimumBodyBytes int64
+}
+
+func (o ExpectContinueOptions) defaults() ExpectContinueOptions {
+	if o.MinimumBodyBytes == 0 {
+		o.MinimumBodyBytes = 1024 * 1024 // 1MB
+	}
+	return o
+}
+
+// NewExpectContinuePolicyFactory creates an ExpectContinuePolicyFactory object
+// that sets the request's Expect header for large PUT/POST requests.
+func NewExpectContinuePolicyFactory(o ExpectContinueOptions) pipeline.Factory {
+	return &expectContinuePolicyFactory{o: o.defaults()}
+}
+
+type expectContinuePolicyFactory struct {
+	o ExpectContinueOptions
+}
+
+// New creates an ExpectContinuePolicy object.
+func (f *expectContinuePolicyFactory) New(node pipeline.Node) pipeline.Policy {
+	return &expectContinuePolicy{node: node, o: f.o}
+}
+
+type expectContinuePolicy struct {
+	node pipeline.Node
+	o    ExpectContinueOptions
+}
+
+func (p *expectContinuePolicy) Do(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+	if (request.Method == http.MethodPut || request.Method == http.MethodPost) &&
+		request.ContentLength >= p.o.MinimumBodyBytes {
+		request.Header.Set("Expect", "100-continue")
+	}
+	return p.node.Do(ctx, request)
+}
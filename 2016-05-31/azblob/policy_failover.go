@@ -0,0 +1,132 @@
+package azblob
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+)
+
+// FailoverOptions configures the failover policy's behavior.
+type FailoverOptions struct {
+	// SecondaryHost is the host of the account's read-only secondary endpoint; for example,
+	// "myaccount-secondary.blob.core.windows.net". It must be set.
+	SecondaryHost string
+
+	// Cooldown indicates how long reads are sent to the secondary after the primary times out
+	// before a read is tried against the primary again (0=default of 30 seconds).
+	Cooldown time.Duration
+}
+
+func (o FailoverOptions) defaults() FailoverOptions {
+	if o.SecondaryHost == "" {
+		panic("SecondaryHost must be set")
+	}
+	if o.Cooldown < 0 {
+		panic("Cooldown must be >= 0")
+	}
+	if o.Cooldown == 0 {
+		o.Cooldown = 30 * time.Second
+	}
+	return o
+}
+
+// FailoverMetrics reports what a failover policy has observed since it was created.
+type FailoverMetrics struct {
+	PrimaryDown       bool  // true while reads are being sent to the secondary
+	PrimaryRequests   int64 // Tries sent to the primary
+	SecondaryRequests int64 // Tries sent to the secondary
+	Failovers         int64 // Number of times a primary timeout moved reads to the secondary
+}
+
+// FailoverPolicyFactory is a pipeline.Factory whose policies share a single view of the primary endpoint's health.
+// Writes always go to the primary. Reads (GET/HEAD) go to the primary until a try against it times out; then
+// reads go to the secondary for Cooldown before the primary is tried again. Place it after the retry policy's
+// factory (see PipelineOptions.Failover) so that the retry following a primary timeout is sent to the secondary.
+// Don't combine it with RetryOptions.RetryReadsFromSecondaryHost.
+// NOTE: Data read from the secondary may be stale; see RetryOptions.RetryReadsFromSecondaryHost.
+type FailoverPolicyFactory struct {
+	o         FailoverOptions
+	lock      sync.Mutex
+	metrics   FailoverMetrics
+	primaryAt time.Time // When reads may be sent to the primary again
+}
+
+// NewFailoverPolicyFactory creates a FailoverPolicyFactory object configured using the specified options.
+func NewFailoverPolicyFactory(o FailoverOptions) *FailoverPolicyFactory {
+	return &FailoverPolicyFactory{o: o.defaults()}
+}
+
+// New creates a FailoverPolicy object.
+func (f *FailoverPolicyFactory) New(node pipeline.Node) pipeline.Policy {
+	return &failoverPolicy{node: node, factory: f}
+}
+
+// Metrics returns a snapshot of the failover policy's metrics.
+func (f *FailoverPolicyFactory) Metrics() FailoverMetrics {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.metrics.PrimaryDown = pkgClock.Now().Before(f.primaryAt)
+	return f.metrics
+}
+
+// ServedBySecondary returns true if the response was served by the secondary endpoint.
+func (f *FailoverPolicyFactory) ServedBySecondary(response *http.Response) bool {
+	return response != nil && response.Request != nil && response.Request.URL.Host == f.o.SecondaryHost
+}
+
+// useSecondary returns true if a try of a request with the specified method should be sent to the secondary.
+func (f *FailoverPolicyFactory) useSecondary(method string) bool {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	secondary := (method == http.MethodGet || method == http.MethodHead) && pkgClock.Now().Before(f.primaryAt)
+	if secondary {
+		f.metrics.SecondaryRequests++
+	} else {
+		f.metrics.PrimaryRequests++
+	}
+	return secondary
+}
+
+// primaryTimedOut starts the cooldown during which reads are sent to the secondary.
+func (f *FailoverPolicyFactory) primaryTimedOut() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	now := pkgClock.Now()
+	if !now.Before(f.primaryAt) {
+		f.metrics.Failovers++
+	}
+	f.primaryAt = now.Add(f.o.Cooldown)
+}
+
+type failoverPolicy struct {
+	node    pipeline.Node
+	factory *FailoverPolicyFactory
+}
+
+func (p *failoverPolicy) Do(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+	secondary := p.factory.useSecondary(request.Method)
+	if secondary {
+		request.URL.Host = p.factory.o.SecondaryHost // The retry policy gives each try its own copy of the request
+	}
+	response, err := p.node.Do(ctx, request)
+	if !secondary && isTimeout(err) {
+		p.factory.primaryTimedOut()
+	}
+	if response != nil && response.Response() != nil && response.Response().Request == nil {
+		response.Response().Request = request.Request // Record which endpoint served the request
+	}
+	return response, err
+}
+
+// isTimeout returns true if err indicates that a try timed out.
+func isTimeout(err error) bool {
+	if err == context.DeadlineExceeded {
+		return true
+	}
+	nerr, ok := err.(net.Error)
+	return ok && nerr.Timeout()
+}
@@ -0,0 +1,47 @@
+package azblob
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+)
+
+// NewHookPolicyFactory creates a HookPolicyFactory object that invokes requestHook before each try is
+// sent and responseHook after each try completes. Either hook may be nil. If a try fails with a
+// ResponseError, responseHook gets the error's HTTP response so it can see the status code.
+func NewHookPolicyFactory(requestHook func(*http.Request), responseHook func(*http.Response, error)) pipeline.Factory {
+	return &hookPolicyFactory{requestHook: requestHook, responseHook: responseHook}
+}
+
+type hookPolicyFactory struct {
+	requestHook  func(*http.Request)
+	responseHook func(*http.Response, error)
+}
+
+// New creates a HookPolicy object.
+func (f *hookPolicyFactory) New(node pipeline.Node) pipeline.Policy {
+	return &hookPolicy{node: node, factory: f}
+}
+
+type hookPolicy struct {
+	node    pipeline.Node
+	factory *hookPolicyFactory
+}
+
+func (p *hookPolicy) Do(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+	if p.factory.requestHook != nil {
+		p.factory.requestHook(request.Request)
+	}
+	response, err := p.node.Do(ctx, request)
+	if p.factory.responseHook != nil {
+		var httpResponse *http.Response
+		if response != nil {
+			httpResponse = response.Response()
+		} else if re, ok := err.(ResponseError); ok {
+			httpResponse = re.Response() // The service returned a failure status code
+		}
+		p.factory.responseHook(httpResponse, err)
+	}
+	return response, err
+}
@@ -0,0 +1,74 @@
+package azblob
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+)
+
+// DualStackOptions configures the dual-stack HTTP sender's behavior.
+type DualStackOptions struct {
+	// FallbackDelay indicates how long a connection attempt to the host's first address family waits before a
+	// racing attempt to the other family starts; the first to connect wins (0=default of 300 milliseconds).
+	FallbackDelay time.Duration
+}
+
+func (o DualStackOptions) defaults() DualStackOptions {
+	if o.FallbackDelay < 0 {
+		panic("FallbackDelay must be >= 0")
+	}
+	if o.FallbackDelay == 0 {
+		o.FallbackDelay = 300 * time.Millisecond
+	}
+	return o
+}
+
+// NewDualStackHTTPSenderFactory creates a pipeline.Factory that sends HTTP requests over connections dialed
+// with "Happy Eyeballs" (RFC 6555/8305): when a host has both IPv4 and IPv6 addresses, connection attempts to
+// both families race so a broken family costs at most FallbackDelay. Pass it as PipelineOptions.HTTPSender.
+func NewDualStackHTTPSenderFactory(o DualStackOptions) pipeline.Factory {
+	o = o.defaults()
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, FallbackDelay: o.FallbackDelay}
+	return newHTTPClientSenderFactory(dialer.DialContext)
+}
+
+// newHTTPClientSenderFactory creates a pipeline.Factory whose policy sends HTTP requests with an
+// http.Client whose Transport dials connections with dialContext.
+func newHTTPClientSenderFactory(dialContext func(ctx context.Context, network, address string) (net.Conn, error)) pipeline.Factory {
+	client := &http.Client{
+		Transport: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			DialContext:           dialContext,
+			MaxIdleConns:          0, // No limit
+			MaxIdleConnsPerHost:   100,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: 1 * time.Second,
+		},
+	}
+	return &httpClientSenderFactory{client: client}
+}
+
+type httpClientSenderFactory struct {
+	client *http.Client
+}
+
+// New creates an HTTP sender policy; it is the last node in the pipeline so node is ignored.
+func (f *httpClientSenderFactory) New(node pipeline.Node) pipeline.Policy {
+	return &httpClientSender{client: f.client}
+}
+
+type httpClientSender struct {
+	client *http.Client
+}
+
+func (s *httpClientSender) Do(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+	response, err := s.client.Do(request.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return pipeline.NewHTTPResponse(response), nil
+}
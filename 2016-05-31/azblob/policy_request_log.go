@@ -66,18 +66,34 @@ func redactSigQueryParam(rawQuery string) (bool, string) {
 
 func prepareRequestForLogging(request pipeline.Request) *http.Request {
 	req := request
+	copied := false
 	if sigFound, rawQuery := redactSigQueryParam(req.URL.RawQuery); sigFound {
 		// Make copy so we don't destroy the query parameters we actually need to send in the request
-		req = request.Copy()
+		req, copied = request.Copy(), true
 		req.Request.URL.RawQuery = rawQuery
 	}
+	// A copy's source URL may also have a SAS (see NewSASCopySourceURL)
+	if copySource, err := url.Parse(req.Header.Get("x-ms-copy-source")); err == nil {
+		if sigFound, rawQuery := redactSigQueryParam(copySource.RawQuery); sigFound {
+			if !copied {
+				req = request.Copy()
+			}
+			header := make(http.Header, len(req.Header))
+			for k, v := range req.Header {
+				header[k] = v
+			}
+			copySource.RawQuery = rawQuery
+			header.Set("x-ms-copy-source", copySource.String())
+			req.Request.Header = header
+		}
+	}
 	return req.Request
 }
 
 func (p *requestLogPolicy) Do(ctx context.Context, request pipeline.Request) (response pipeline.Response, err error) {
 	p.try++ // The first try is #1 (not #0)
 	if p.try == 1 {
-		p.operationStart = time.Now() // If this is the 1st try, record the operation state time
+		p.operationStart = pkgClock.Now() // If this is the 1st try, record the operation state time
 	}
 
 	// Log the outgoing request as informational
@@ -89,9 +105,9 @@ func (p *requestLogPolicy) Do(ctx context.Context, request pipeline.Request) (re
 	}
 
 	// Set the time for this particular retry operation and then Do the operation.
-	tryStart := time.Now()
+	tryStart := pkgClock.Now()
 	response, err = p.node.Do(ctx, request) // Make the request
-	tryEnd := time.Now()
+	tryEnd := pkgClock.Now()
 	tryDuration := tryEnd.Sub(tryStart)
 	opDuration := tryEnd.Sub(p.operationStart)
 
@@ -141,7 +157,8 @@ func (p *requestLogPolicy) Do(ctx context.Context, request pipeline.Request) (re
 	if shouldLog := p.node.ShouldLog(severity); forceLog || shouldLog {
 		// We're going to log this; build the string to log
 		b := &bytes.Buffer{}
-		fmt.Fprintf(b, "==> REQUEST/RESPONSE (Try=%d, TryDuration=%v, OpDuration=%v) -- ", p.try, tryDuration, opDuration)
+		fmt.Fprintf(b, "==> REQUEST/RESPONSE (Try=%d, TryDuration=%v, OpDuration=%v, ClientRequestID=%s) -- ",
+			p.try, tryDuration, opDuration, request.Header.Get(xMsClientRequestID))
 		logMsg(b)
 		msg := b.String()
 
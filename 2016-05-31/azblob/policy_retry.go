@@ -6,6 +6,7 @@ import (
 	"math/rand"
 	"net"
 	"net/http"
+	"net/url"
 	"strconv"
 	"time"
 
@@ -38,6 +39,7 @@ type RetryOptions struct {
 	// of data, the default TryTimeout will probably not be sufficient. You should override this value
 	// based on the bandwidth available to the host machine and proximity to the Storage service. A good
 	// starting point may be something like (60 seconds per MB of anticipated-payload-size).
+	// If the operation's context has a deadline, each try is also limited to the time remaining until that deadline.
 	TryTimeout time.Duration
 
 	// RetryDelay specifies the amount of delay to use before retrying an operation (0=default).
@@ -54,8 +56,35 @@ type RetryOptions struct {
 	// NOTE: Before setting this field, make sure you understand the issues around reading stale & potentially-inconsistent
 	// data at this webpage: https://docs.microsoft.com/en-us/azure/storage/common/storage-designing-ha-apps-with-ragrs
 	RetryReadsFromSecondaryHost string
+
+	// NotifyRetry, if not nil, is called each time the policy decides to retry an operation; it is called before
+	// the policy delays. try is the number of the upcoming try (>= 2), delay is how long the policy will wait
+	// before making it, and err & resp are the error and HTTP response (either may be nil) of the try that failed.
+	// NotifyRetry is for observability (metrics/logging) only; it must not retain or modify resp.
+	NotifyRetry func(try int32, delay time.Duration, err error, resp *http.Response)
+
+	// Metrics, if not nil, is told the category of the failure behind each retry (see RetryMetrics).
+	Metrics RetryMetrics
 }
 
+// RetryMetrics receives a count of the retries made by the retry policy, by the category of the failure that
+// was retried. Implement it to feed counters in your metrics system (one counter per category, for example).
+// IncRetry is called from the goroutines making requests so it must be goroutine-safe.
+type RetryMetrics interface {
+	IncRetry(category string)
+}
+
+// The categories of failures passed to RetryMetrics.IncRetry.
+const (
+	RetryCategoryThrottled         = "throttled"           // The service returned 503 (Service Unavailable)
+	RetryCategoryServerError       = "server_error"        // The service returned another 5xx status, like 500 (Internal Server Error)
+	RetryCategorySecondaryNotFound = "secondary_not_found" // The secondary host returned 404 (Not Found)
+	RetryCategoryTimeout           = "timeout"             // The try timed out
+	RetryCategoryDial              = "dial"                // Connecting to the service failed
+	RetryCategoryRead              = "read"                // Reading the response (headers or body) failed
+	RetryCategoryOther             = "other"               // Any other temporary error
+)
+
 func (o RetryOptions) defaults() RetryOptions {
 	if (o.RetryDelay == 0 && o.MaxRetryDelay != 0) || (o.RetryDelay != 0 && o.MaxRetryDelay == 0) {
 		panic(errors.New("Both RetryDelay and MaxRetryDelay must be 0 or neither can be 0"))
@@ -85,6 +114,34 @@ func (o RetryOptions) defaults() RetryOptions {
 	return o
 }
 
+// retryCategory returns the RetryCategory* constant describing the failed try that produced response and err.
+func retryCategory(response pipeline.Response, err error) string {
+	if response != nil && response.Response() != nil {
+		switch statusCode := response.Response().StatusCode; {
+		case statusCode == http.StatusServiceUnavailable:
+			return RetryCategoryThrottled
+		case statusCode == http.StatusNotFound:
+			return RetryCategorySecondaryNotFound
+		case statusCode >= 500:
+			return RetryCategoryServerError
+		}
+	}
+	if uerr, ok := err.(*url.Error); ok {
+		err = uerr.Err // The HTTP client wraps its errors
+	}
+	oerr, _ := err.(*net.OpError)
+	nerr, _ := err.(net.Error)
+	switch {
+	case oerr != nil && oerr.Op == "dial":
+		return RetryCategoryDial
+	case err == context.DeadlineExceeded || (nerr != nil && nerr.Timeout()):
+		return RetryCategoryTimeout
+	case oerr != nil && oerr.Op == "read":
+		return RetryCategoryRead
+	}
+	return RetryCategoryOther
+}
+
 func (o RetryOptions) calcDelay(try int32) time.Duration { // try is >=1; never 0
 	pow := func(number int64, exponent int32) int64 { // pow is nested helper function
 		var result int64 = 1
@@ -106,13 +163,59 @@ func (o RetryOptions) calcDelay(try int32) time.Duration { // try is >=1; never
 	}
 
 	// Introduce some jitter:  [0.0, 1.0) / 2 = [0.0, 0.5) + 0.8 = [0.8, 1.3)
-	delay *= time.Duration(rand.Float32()/2 + 0.8) // NOTE: We want math/rand; not crypto/rand
+	delay = time.Duration(float32(delay) * (rand.Float32()/2 + 0.8)) // NOTE: We want math/rand; not crypto/rand
 	if delay > o.MaxRetryDelay {
 		delay = o.MaxRetryDelay
 	}
 	return delay
 }
 
+// calcTryTimeout returns the maximum duration allowed for a single try. This is TryTimeout unless the
+// user's ctx has a deadline that arrives sooner; in that case, the try may only run until the deadline.
+func (o RetryOptions) calcTryTimeout(ctx context.Context) time.Duration {
+	timeout := o.TryTimeout
+	if deadline, ok := ctx.Deadline(); ok { // If user's ctx has a deadline, make the timeout the smaller of the two
+		t := deadline.Sub(pkgClock.Now()) // Duration from now until user's ctx reaches its deadline
+		logf("MaxTryTimeout=%v, TimeTilDeadline=%v\n", timeout, t)
+		if t < timeout {
+			timeout = t
+		}
+		if timeout < 0 {
+			timeout = 0 // If timeout ever goes negative, set it to zero; this happen while debugging
+		}
+		logf("TryTimeout adjusted to=%v\n", timeout)
+	}
+	return timeout
+}
+
+// serverTimeoutSeconds converts a try's timeout to the whole number of seconds sent in the "timeout" query parameter.
+// Partial seconds are rounded up so that a try with less than a second remaining doesn't ask the service for a 0 timeout.
+func serverTimeoutSeconds(tryTimeout time.Duration) int32 {
+	seconds := int32(tryTimeout / time.Second)
+	if tryTimeout%time.Second != 0 {
+		seconds++
+	}
+	return seconds
+}
+
+type serverTimeoutKey struct{}
+
+// MaxServerTimeoutSeconds indicates the largest server timeout (the "timeout" query parameter) that WithServerTimeout
+// accepts: 10 minutes, the longest the Blob service allows any operation.
+const MaxServerTimeoutSeconds = 10 * 60
+
+// WithServerTimeout returns a context that limits the time the service spends on each try of a request made with it
+// to seconds (the "timeout" query parameter) so a slow operation fails promptly with OperationTimedOut. The retry
+// policy sets the parameter for every try anyway (from TryTimeout); it uses seconds instead if that's smaller.
+// seconds must be > 0 and <= MaxServerTimeoutSeconds. For more information, see
+// https://docs.microsoft.com/rest/api/storageservices/setting-timeouts-for-blob-service-operations.
+func WithServerTimeout(ctx context.Context, seconds int32) context.Context {
+	if seconds <= 0 || seconds > MaxServerTimeoutSeconds {
+		panic("seconds must be > 0 and <= MaxServerTimeoutSeconds")
+	}
+	return context.WithValue(ctx, serverTimeoutKey{}, seconds)
+}
+
 // NewRetryPolicyFactory creates a RetryPolicyFactory object configured using the specified options.
 func NewRetryPolicyFactory(o RetryOptions) pipeline.Factory {
 	return &retryPolicyFactory{o: o.defaults()}
@@ -160,15 +263,37 @@ func (p *retryPolicy) Do(ctx context.Context, request pipeline.Request) (respons
 		// Determine which endpoint to try. It's primary if there is no secondary or if it is an add # attempt.
 		tryingPrimary := !considerSecondary || (try%2 == 1)
 		// Select the correct host and delay
+		var delay time.Duration
 		if tryingPrimary {
 			primaryTry++
-			delay := p.o.calcDelay(primaryTry)
+			delay = p.o.calcDelay(primaryTry) // The 1st try returns 0 delay
 			logf("Primary try=%d, Delay=%v\n", primaryTry, delay)
-			time.Sleep(delay) // The 1st try returns 0 delay
 		} else {
-			delay := time.Second * time.Duration(rand.Float32()/2+0.8)
+			delay = time.Duration(float32(time.Second) * (rand.Float32()/2 + 0.8)) // Delay with some jitter before trying secondary
 			logf("Secondary try=%d, Delay=%v\n", try-primaryTry, delay)
-			time.Sleep(delay) // Delay with some jitter before trying secondary
+		}
+		if deadline, ok := ctx.Deadline(); ok && try > 1 && delay >= deadline.Sub(pkgClock.Now()) {
+			// Sleeping would use up the rest of the user's ctx; return the last response/error now instead
+			logf("Delay=%v exceeds the time remaining until the ctx deadline; no more retries\n", delay)
+			break
+		}
+		if try > 1 && p.o.NotifyRetry != nil {
+			var resp *http.Response
+			if response != nil {
+				resp = response.Response()
+			}
+			p.o.NotifyRetry(try, delay, err, resp)
+		}
+		if try > 1 && p.o.Metrics != nil {
+			p.o.Metrics.IncRetry(retryCategory(response, err))
+		}
+		if delay > 0 {
+			select {
+			case <-ctx.Done():
+				logf("ctx done while delaying; no more retries\n")
+				return response, err // Return the last response/error
+			case <-pkgClock.After(delay):
+			}
 		}
 
 		// Clone the original request to ensure that each try starts with the original (unmutated) request.
@@ -183,26 +308,21 @@ func (p *retryPolicy) Do(ctx context.Context, request pipeline.Request) (respons
 			requestCopy.Request.URL.Host = secondaryHost
 		}
 
+		// Compute this try's timeout: the smaller of TryTimeout and the time remaining until the user's ctx deadline
+		tryTimeout := p.o.calcTryTimeout(ctx)
+
 		// Set the server-side timeout query parameter "timeout=[seconds]"
-		timeout := int32(p.o.TryTimeout.Seconds()) // Max seconds per try
-		if deadline, ok := ctx.Deadline(); ok {    // If user's ctx has a deadline, make the timeout the smaller of the two
-			t := int32(deadline.Sub(time.Now()).Seconds()) // Duration from now until user's ctx reaches its deadline
-			logf("MaxTryTimeout=%d secs, TimeTilDeadline=%d sec\n", timeout, t)
-			if t < timeout {
-				timeout = t
-			}
-			if timeout < 0 {
-				timeout = 0 // If timeout ever goes negative, set it to zero; this happen while debugging
-			}
-			logf("TryTimeout adjusted to=%d sec\n", timeout)
-		}
 		q := requestCopy.Request.URL.Query()
+		timeout := serverTimeoutSeconds(tryTimeout)
+		if seconds, ok := ctx.Value(serverTimeoutKey{}).(int32); ok && seconds < timeout {
+			timeout = seconds
+		}
 		q.Set("timeout", strconv.Itoa(int(timeout)))
 		requestCopy.Request.URL.RawQuery = q.Encode()
 		logf("Url=%s\n", requestCopy.Request.URL.String())
 
 		// Set the time for this particular retry operation and then Do the operation.
-		tryCtx, tryCancel := context.WithTimeout(ctx, time.Second*time.Duration(timeout))
+		tryCtx, tryCancel := context.WithTimeout(ctx, tryTimeout)
 		response, err = p.node.Do(tryCtx, requestCopy) // Make the request
 		logf("Err=%v, response=%v\n", err, response)
 
@@ -0,0 +1,44 @@
+package azblob
+
+import (
+	"context"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+)
+
+type serviceVersionKey struct{}
+
+// WithServiceVersion returns a context that makes the pipeline send requests made with it with version as their
+// x-ms-version header instead of ServiceVersion. Use it to call a single operation on a newer service version, for
+// example to pass a header only that version accepts, without changing the version used by all other requests.
+// The pipeline returned by NewPipeline applies the version; a custom pipeline needs NewServiceVersionPolicyFactory.
+func WithServiceVersion(ctx context.Context, version string) context.Context {
+	if version == "" {
+		panic("version can't be empty")
+	}
+	return context.WithValue(ctx, serviceVersionKey{}, version)
+}
+
+// NewServiceVersionPolicyFactory creates a factory whose policies set the x-ms-version header of requests whose
+// context has a service version (see WithServiceVersion). Place it before the credential so the header is signed.
+func NewServiceVersionPolicyFactory() pipeline.Factory {
+	return &serviceVersionPolicyFactory{}
+}
+
+type serviceVersionPolicyFactory struct{}
+
+// New creates a ServiceVersionPolicy object.
+func (f *serviceVersionPolicyFactory) New(node pipeline.Node) pipeline.Policy {
+	return &serviceVersionPolicy{node: node}
+}
+
+type serviceVersionPolicy struct {
+	node pipeline.Node
+}
+
+func (p *serviceVersionPolicy) Do(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+	if version, ok := ctx.Value(serviceVersionKey{}).(string); ok {
+		request.Header.Set(headerXmsVersion, version)
+	}
+	return p.node.Do(ctx, request)
+}
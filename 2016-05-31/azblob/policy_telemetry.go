@@ -15,17 +15,25 @@ type TelemetryOptions struct {
 	// Value is a string prepended to each request's User-Agent and sent to the service.
 	// The service records the user-agent in logs for diagnostics and tracking of client requests.
 	Value string
+
+	// Suffix indicates whether Value is appended to the User-Agent (after the SDK's tokens) instead of prepended.
+	Suffix bool
 }
 
 // NewTelemetryPolicyFactory creates a factory that can create telemetry policy objects
-// which add telemetry information to outgoing HTTP requests.
+// which add telemetry information to outgoing HTTP requests. The User-Agent is
+// "azsdk-go-azblob/<SDKVersion> (<Go version>; <OS>)" with o.Value before or after it.
 func NewTelemetryPolicyFactory(o TelemetryOptions) pipeline.Factory {
 	b := &bytes.Buffer{}
-	b.WriteString(o.Value)
-	if b.Len() > 0 {
+	if o.Value != "" && !o.Suffix {
+		b.WriteString(o.Value)
+		b.WriteRune(' ')
+	}
+	fmt.Fprintf(b, "azsdk-go-azblob/%s %s", SDKVersion, platformInfo)
+	if o.Value != "" && o.Suffix {
 		b.WriteRune(' ')
+		b.WriteString(o.Value)
 	}
-	fmt.Fprintf(b, "Azure-Storage/%s %s", serviceLibVersion, platformInfo)
 	return &telemetryPolicyFactory{telemetryValue: b.String()}
 }
 
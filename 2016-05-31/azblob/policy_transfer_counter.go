@@ -0,0 +1,87 @@
+package azblob
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+)
+
+// A TransferCounter accumulates the number of HTTP body bytes sent and received by every request made with a
+// context returned by WithTransferCounter. Every try is counted so the totals include the bytes of retried requests.
+// Use one TransferCounter per logical operation (or tenant) to attribute bandwidth to it. It is goroutine-safe.
+type TransferCounter struct {
+	requests      int64
+	requestBytes  int64
+	responseBytes int64
+}
+
+// Requests returns the number of HTTP requests (including retries) sent so far.
+func (c *TransferCounter) Requests() int64 { return atomic.LoadInt64(&c.requests) }
+
+// RequestBytes returns the number of request body bytes sent so far.
+func (c *TransferCounter) RequestBytes() int64 { return atomic.LoadInt64(&c.requestBytes) }
+
+// ResponseBytes returns the number of response body bytes read so far. A response body is counted as it is read
+// so the total only includes a download's bytes once the caller has read them.
+func (c *TransferCounter) ResponseBytes() int64 { return atomic.LoadInt64(&c.responseBytes) }
+
+type transferCounterKey struct{}
+
+// WithTransferCounter returns a context that makes the pipeline add the bytes of each request made with it to counter.
+// The pipeline returned by NewPipeline does the counting; a custom pipeline needs NewTransferCounterPolicyFactory.
+func WithTransferCounter(ctx context.Context, counter *TransferCounter) context.Context {
+	if counter == nil {
+		panic("counter can't be nil")
+	}
+	return context.WithValue(ctx, transferCounterKey{}, counter)
+}
+
+// NewTransferCounterPolicyFactory creates a factory whose policies count the body bytes of requests whose context
+// has a TransferCounter (see WithTransferCounter). Place it after pipeline.MethodFactoryMarker so that each try is
+// counted and response bodies read by the method's responder are seen.
+func NewTransferCounterPolicyFactory() pipeline.Factory {
+	return &transferCounterPolicyFactory{}
+}
+
+type transferCounterPolicyFactory struct{}
+
+// New creates a TransferCounterPolicy object.
+func (f *transferCounterPolicyFactory) New(node pipeline.Node) pipeline.Policy {
+	return &transferCounterPolicy{node: node}
+}
+
+type transferCounterPolicy struct {
+	node pipeline.Node
+}
+
+func (p *transferCounterPolicy) Do(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+	counter, ok := ctx.Value(transferCounterKey{}).(*TransferCounter)
+	if !ok {
+		return p.node.Do(ctx, request)
+	}
+	atomic.AddInt64(&counter.requests, 1)
+	if request.Body != nil {
+		// Each try gets its own copy of the request (see the retry policy) so wrapping this try's body is safe
+		request.Body = &countingReadCloser{ReadCloser: request.Body, count: &counter.requestBytes}
+	}
+	response, err := p.node.Do(ctx, request)
+	if response != nil && response.Response() != nil && response.Response().Body != nil {
+		r := response.Response()
+		r.Body = &countingReadCloser{ReadCloser: r.Body, count: &counter.responseBytes}
+	}
+	return response, err
+}
+
+// countingReadCloser adds the number of bytes read through it to count.
+type countingReadCloser struct {
+	io.ReadCloser
+	count *int64
+}
+
+func (c *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	atomic.AddInt64(c.count, int64(n))
+	return n, err
+}
@@ -39,6 +39,15 @@ func (p *uniqueRequestIDPolicy) Do(ctx context.Context, request pipeline.Request
 	return p.node.Do(ctx, request)
 }
 
+// ClientRequestID returns the x-ms-client-request-id sent with the request that produced the response.
+// The response may be any operation's response or a StorageError. Provide this ID when opening a support case.
+func ClientRequestID(response pipeline.Response) string {
+	if response == nil || response.Response() == nil || response.Response().Request == nil {
+		return ""
+	}
+	return response.Response().Request.Header.Get(xMsClientRequestID)
+}
+
 // The UUID reserved variants.
 const (
 	reservedNCS       byte = 0x80
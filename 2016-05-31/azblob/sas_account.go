@@ -53,22 +53,7 @@ func (v AccountSASSignatureValues) NewSASQueryParameters(sharedKeyCredential *Sh
 	if v.Version == "" {
 		v.Version = SASVersion
 	}
-	startTime, expiryTime := FormatTimesForSASSigning(v.StartTime, v.ExpiryTime)
-
-	stringToSign := strings.Join([]string{
-		sharedKeyCredential.AccountName(),
-		v.Permissions,
-		v.Services,
-		v.ResourceTypes,
-		startTime,
-		expiryTime,
-		v.IPRange.String(),
-		v.Protocol,
-		v.Version,
-		""}, // That right, the account SAS requires a terminating extra newline
-		"\n")
-
-	signature := sharedKeyCredential.ComputeHMACSHA256(stringToSign)
+	signature := sharedKeyCredential.ComputeHMACSHA256(v.stringToSign(sharedKeyCredential.AccountName()))
 	p := SASQueryParameters{
 		// Common SAS parameters
 		Version:     v.Version,
@@ -88,6 +73,32 @@ func (v AccountSASSignatureValues) NewSASQueryParameters(sharedKeyCredential *Sh
 	return p
 }
 
+// StringToSign returns the exact string NewSASQueryParameters signs for these values and accountName. It contains
+// no secrets so it is safe to log when diagnosing a SAS that the service rejects with 403 (Forbidden).
+func (v AccountSASSignatureValues) StringToSign(accountName string) string {
+	if v.Version == "" {
+		v.Version = SASVersion
+	}
+	return v.stringToSign(accountName)
+}
+
+// stringToSign returns the string signed to produce an account SAS for accountName.
+func (v AccountSASSignatureValues) stringToSign(accountName string) string {
+	startTime, expiryTime := FormatTimesForSASSigning(v.StartTime, v.ExpiryTime)
+	return strings.Join([]string{
+		accountName,
+		v.Permissions,
+		v.Services,
+		v.ResourceTypes,
+		startTime,
+		expiryTime,
+		v.IPRange.String(),
+		v.Protocol,
+		v.Version,
+		""}, // That right, the account SAS requires a terminating extra newline
+		"\n")
+}
+
 // The AccountSASPermissions type simplifies creating the permissions string for an Azure Storage Account SAS.
 // Initialize an instance of this type and then call its String method to set AccountSASSignatureValues's Permissions field.
 type AccountSASPermissions struct {
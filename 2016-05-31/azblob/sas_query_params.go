@@ -1,6 +1,8 @@
 package azblob
 
 import (
+	"crypto/subtle"
+	"errors"
 	"net"
 	"net/url"
 	"strings"
@@ -99,6 +101,37 @@ func NewSASQueryParameters(values url.Values, deleteSASParametersFromValues bool
 	return p
 }
 
+// ErrSASSignatureMismatch is returned by SASQueryParameters.Verify when a SAS' signature doesn't match its parameters.
+var ErrSASSignatureMismatch = errors.New("the SAS signature doesn't match its parameters")
+
+// Verify recomputes the signature of the SAS' parameters with sharedKeyCredential and compares it (in constant time)
+// to the SAS' Signature, returning ErrSASSignatureMismatch if they differ. An account SAS (Services is set) ignores
+// containerName and blobName; a container or blob SAS is verified as granting access to the named container or blob.
+// NOTE: The rscc, rscd, rsce, rscl and rsct response headers are not SAS query parameters here so they must be empty.
+func (p *SASQueryParameters) Verify(sharedKeyCredential *SharedKeyCredential, containerName, blobName string) error {
+	if sharedKeyCredential == nil {
+		panic("sharedKeyCredential can't be nil")
+	}
+	var stringToSign string
+	if p.Services != "" {
+		stringToSign = AccountSASSignatureValues{Version: p.Version, Protocol: p.Protocol, StartTime: p.StartTime,
+			ExpiryTime: p.ExpiryTime, Permissions: p.Permissions, IPRange: p.IPRange,
+			Services: p.Services, ResourceTypes: p.ResourceTypes}.stringToSign(sharedKeyCredential.AccountName())
+	} else {
+		if (p.Resource == "b") != (blobName != "") {
+			return ErrSASSignatureMismatch // The SAS is for a different kind of resource
+		}
+		stringToSign = BlobSASSignatureValues{Version: p.Version, Protocol: p.Protocol, StartTime: p.StartTime,
+			ExpiryTime: p.ExpiryTime, Permissions: p.Permissions, IPRange: p.IPRange, ContainerName: containerName,
+			BlobName: blobName, Identifier: p.Identifier}.stringToSign(sharedKeyCredential.AccountName())
+	}
+	expected := sharedKeyCredential.ComputeHMACSHA256(stringToSign)
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(p.Signature)) != 1 {
+		return ErrSASSignatureMismatch
+	}
+	return nil
+}
+
 // AddToValues adds the SAS components to the specified query parameters map.
 func (p *SASQueryParameters) AddToValues(v url.Values) url.Values {
 	if p.Version != "" {
@@ -2,6 +2,7 @@ package azblob
 
 import (
 	"bytes"
+	"errors"
 	"strings"
 	"time"
 )
@@ -24,12 +25,22 @@ type BlobSASSignatureValues struct {
 	ContentType        string // rsct
 }
 
+// ErrSASPermissionsRequired is returned by BlobSASSignatureValues' NewSASQueryParameters when neither Permissions
+// nor Identifier is set.
+var ErrSASPermissionsRequired = errors.New("at least one of Identifier and Permissions must be set; use Permissions " +
+	"for an ad hoc SAS or Identifier for a SAS governed by a stored access policy")
+
 // NewSASQueryParameters uses an account's shared key credential to sign this signature values to produce
-// the proper SAS query parameters.
-func (v BlobSASSignatureValues) NewSASQueryParameters(sharedKeyCredential *SharedKeyCredential) SASQueryParameters {
+// the proper SAS query parameters. At least one of Permissions and Identifier must be set (otherwise,
+// ErrSASPermissionsRequired is returned): an ad hoc SAS needs Permissions while a SAS governed by a stored access
+// policy gets its permissions from the policy Identifier names.
+func (v BlobSASSignatureValues) NewSASQueryParameters(sharedKeyCredential *SharedKeyCredential) (SASQueryParameters, error) {
 	if sharedKeyCredential == nil {
 		panic("sharedKeyCredential can't be nil")
 	}
+	if v.Identifier == "" && v.Permissions == "" {
+		return SASQueryParameters{}, ErrSASPermissionsRequired
+	}
 
 	resource := "c"
 	if v.BlobName != "" {
@@ -38,25 +49,7 @@ func (v BlobSASSignatureValues) NewSASQueryParameters(sharedKeyCredential *Share
 	if v.Version == "" {
 		v.Version = SASVersion
 	}
-	startTime, expiryTime := FormatTimesForSASSigning(v.StartTime, v.ExpiryTime)
-
-	// String to sign: http://msdn.microsoft.com/en-us/library/azure/dn140255.aspx
-	stringToSign := strings.Join([]string{
-		v.Permissions,
-		startTime,
-		expiryTime,
-		getCanonicalName(sharedKeyCredential.AccountName(), v.ContainerName, v.BlobName),
-		v.Identifier,
-		v.IPRange.String(),
-		v.Protocol,
-		v.Version,
-		v.CacheControl,       // rscc
-		v.ContentDisposition, // rscd
-		v.ContentEncoding,    // rsce
-		v.ContentLanguage,    // rscl
-		v.ContentType},       // rsct
-		"\n")
-	signature := sharedKeyCredential.ComputeHMACSHA256(stringToSign)
+	signature := sharedKeyCredential.ComputeHMACSHA256(v.stringToSign(sharedKeyCredential.AccountName()))
 
 	p := SASQueryParameters{
 		// Common SAS parameters
@@ -74,7 +67,38 @@ func (v BlobSASSignatureValues) NewSASQueryParameters(sharedKeyCredential *Share
 		// Calculated SAS signature
 		Signature: signature,
 	}
-	return p
+	return p, nil
+}
+
+// StringToSign returns the exact string NewSASQueryParameters signs for these values and accountName. It contains
+// no secrets so it is safe to log when diagnosing a SAS that the service rejects with 403 (Forbidden).
+func (v BlobSASSignatureValues) StringToSign(accountName string) string {
+	if v.Version == "" {
+		v.Version = SASVersion
+	}
+	return v.stringToSign(accountName)
+}
+
+// stringToSign returns the string signed to produce a container or blob SAS for accountName.
+func (v BlobSASSignatureValues) stringToSign(accountName string) string {
+	startTime, expiryTime := FormatTimesForSASSigning(v.StartTime, v.ExpiryTime)
+
+	// String to sign: http://msdn.microsoft.com/en-us/library/azure/dn140255.aspx
+	return strings.Join([]string{
+		v.Permissions,
+		startTime,
+		expiryTime,
+		getCanonicalName(accountName, v.ContainerName, v.BlobName),
+		v.Identifier,
+		v.IPRange.String(),
+		v.Protocol,
+		v.Version,
+		v.CacheControl,       // rscc
+		v.ContentDisposition, // rscd
+		v.ContentEncoding,    // rsce
+		v.ContentLanguage,    // rscl
+		v.ContentType},       // rsct
+		"\n")
 }
 
 // getCanonicalName computes the canonical name for a container or blob resource for SAS signing.
@@ -2,8 +2,11 @@ package azblob
 
 import (
 	"bytes"
+	"context"
 	"encoding/xml"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"sort"
 
@@ -26,33 +29,121 @@ type StorageError interface {
 
 	// ServiceCode returns a service error code. Your code can use this to make error recovery decisions.
 	ServiceCode() ServiceCodeType
+
+	// RawBody returns the start of the response body (up to StorageErrorMaxRawBodyBytes) if it couldn't be
+	// parsed as a storage service error; otherwise nil. A proxy's HTML error page, for example, ends up here.
+	// The pipeline returned by NewPipeline keeps the body; with a custom pipeline, RawBody returns nil.
+	RawBody() []byte
+
+	// RequestID returns the x-ms-request-id response header; Microsoft support needs it to investigate a failure.
+	// It returns "" if there is no response.
+	RequestID() string
+
+	// ClientRequestID returns the x-ms-client-request-id header the request was sent with (see
+	// NewUniqueRequestIDPolicyFactory). It returns "" if there is no response.
+	ClientRequestID() string
 }
 
+// StorageErrorMaxRawBodyBytes indicates the maximum number of bytes of an unparsable error response body kept by a StorageError.
+const StorageErrorMaxRawBodyBytes = 4 * 1024
+
 // storageError is the internat struct that implements the public StorageError interface.
 type storageError struct {
 	responseError
 	serviceCode ServiceCodeType
 	details     map[string]string
+	rawBody     []byte
 }
 
 // newStorageError creates an error object that implements the error interface.
 func newStorageError(cause error, response *http.Response, description string) error {
-	return &storageError{
+	e := &storageError{
 		responseError: responseError{
 			ErrorNode:   pipeline.ErrorNode{}.Initialize(cause, 3),
 			response:    response,
 			description: description,
 		},
 	}
+	if cause != nil && response != nil {
+		// The responder passes a cause when it couldn't read or unmarshal the body; keep what the service sent
+		if body, ok := response.Body.(*errorResponseBody); ok {
+			e.rawBody = body.prefix
+		}
+	}
+	return e
+}
+
+// errorResponseBody replaces an error response's body so the start of it is still available after the responder
+// has read it all.
+type errorResponseBody struct {
+	io.Reader
+	io.Closer
+	prefix []byte // The first StorageErrorMaxRawBodyBytes (at most) of the body
+}
+
+// newErrorResponseBodyPolicyFactory creates a factory whose policies keep the start of every error response's body
+// for StorageError's RawBody. It must come after pipeline.MethodFactoryMarker so it sees responses before the
+// method's responder reads them.
+func newErrorResponseBodyPolicyFactory() pipeline.Factory {
+	return errorResponseBodyPolicyFactory{}
+}
+
+type errorResponseBodyPolicyFactory struct{}
+
+// New creates an errorResponseBodyPolicy object.
+func (errorResponseBodyPolicyFactory) New(node pipeline.Node) pipeline.Policy {
+	return errorResponseBodyPolicy{node: node}
+}
+
+type errorResponseBodyPolicy struct {
+	node pipeline.Node
+}
+
+func (p errorResponseBodyPolicy) Do(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+	response, err := p.node.Do(ctx, request)
+	if err != nil || response == nil || response.Response() == nil {
+		return response, err
+	}
+	r := response.Response()
+	if r.StatusCode >= http.StatusBadRequest && r.Body != nil {
+		prefix, _ := ioutil.ReadAll(io.LimitReader(r.Body, StorageErrorMaxRawBodyBytes)) // A read error recurs when the responder reads the rest
+		r.Body = &errorResponseBody{Reader: io.MultiReader(bytes.NewReader(prefix), r.Body), Closer: r.Body, prefix: prefix}
+	}
+	return response, err
 }
 
 // ServiceCode returns service-error information. The caller may examine these values but should not modify any of them.
 func (e *storageError) ServiceCode() ServiceCodeType { return e.serviceCode }
 
+// RawBody returns the start of the response body if it couldn't be parsed; otherwise nil.
+func (e *storageError) RawBody() []byte { return e.rawBody }
+
+// RequestID returns the x-ms-request-id response header.
+func (e *storageError) RequestID() string {
+	if e.response == nil {
+		return ""
+	}
+	return e.response.Header.Get("x-ms-request-id")
+}
+
+// ClientRequestID returns the x-ms-client-request-id header the request was sent with.
+func (e *storageError) ClientRequestID() string {
+	if e.response == nil {
+		return ""
+	}
+	if id := e.response.Header.Get("x-ms-client-request-id"); id != "" {
+		return id // The service echoes the client's request ID
+	}
+	if e.response.Request != nil {
+		return e.response.Request.Header.Get("x-ms-client-request-id")
+	}
+	return ""
+}
+
 // Error implements the error interface's Error method to return a string representation of the error.
 func (e *storageError) Error() string {
 	b := &bytes.Buffer{}
-	fmt.Fprintf(b, "===== RESPONSE ERROR (ServiceCode=%s) =====\n", e.serviceCode)
+	fmt.Fprintf(b, "===== RESPONSE ERROR (ServiceCode=%s, RequestID=%s) =====\n", e.serviceCode, e.RequestID())
 	fmt.Fprintf(b, "Description=%s, Details: ", e.description)
 	if len(e.details) == 0 {
 		b.WriteString("(none)\n")
@@ -68,6 +159,9 @@ func (e *storageError) Error() string {
 			fmt.Fprintf(b, "   %s: %+v\n", k, e.details[k])
 		}
 	}
+	if e.rawBody != nil {
+		fmt.Fprintf(b, "RawBody=%s\n", e.rawBody)
+	}
 	req := pipeline.Request{Request: e.response.Request}.Copy() // Make a copy of the response's request
 	pipeline.WriteRequestWithResponse(b, prepareRequestForLogging(req), e.response)
 	return e.ErrorNode.Error(b.String())
@@ -85,6 +179,9 @@ func (e *storageError) Temporary() bool {
 
 // UnmarshalXML performs custom unmarshalling of XML-formatted Azure storage request errors.
 func (e *storageError) UnmarshalXML(d *xml.Decoder, start xml.StartElement) (err error) {
+	if start.Name.Local != "Error" {
+		return fmt.Errorf("unexpected root element <%s>", start.Name.Local) // Not a storage service error (an HTML page, for example)
+	}
 	tokName := ""
 	var t xml.Token
 	for t, err = d.Token(); err == nil; t, err = d.Token() {
@@ -106,5 +203,8 @@ func (e *storageError) UnmarshalXML(d *xml.Decoder, start xml.StartElement) (err
 			}
 		}
 	}
+	if err != io.EOF {
+		return err
+	}
 	return nil
 }
@@ -2,6 +2,7 @@ package azblob
 
 import (
 	"context"
+	"errors"
 	"io"
 	"net/url"
 	"time"
@@ -9,6 +10,18 @@ import (
 	"github.com/Azure/azure-pipeline-go/pipeline"
 )
 
+const (
+	// AppendBlobMaxAppendBlockBytes indicates the maximum number of bytes that can be sent in a call to AppendBlock.
+	AppendBlobMaxAppendBlockBytes = 4 * 1024 * 1024 // 4MB
+
+	// AppendBlobMaxBlocks indicates the maximum number of blocks allowed in an append blob.
+	AppendBlobMaxBlocks = 50000
+)
+
+// ErrAppendBlockTooLarge is returned (without contacting the service) by AppendBlock when the block's body is larger
+// than AppendBlobMaxAppendBlockBytes.
+var ErrAppendBlockTooLarge = errors.New("the block is larger than AppendBlobMaxAppendBlockBytes")
+
 // AppendBlobURL defines a set of operations applicable to append blobs.
 type AppendBlobURL struct {
 	BlobURL
@@ -36,6 +49,10 @@ func (ab AppendBlobURL) WithSnapshot(snapshot time.Time) AppendBlobURL {
 }
 
 // Create creates a 0-length append blob. Call AppendBlock to append data to an append blob.
+// By default, Create replaces an existing blob. When several writers may race to create the same blob,
+// pass an ac with HTTPAccessConditions.IfNoneMatch set to ETagAny: exactly one Create succeeds and the others fail
+// with ServiceCodeBlobAlreadyExists (or ServiceCodeConditionNotMet), so no writer discards another's appended blocks.
+// CreateIfNotExists wraps this pattern.
 // For more information, see https://docs.microsoft.com/rest/api/storageservices/put-blob.
 func (ab AppendBlobURL) Create(ctx context.Context, metadata Metadata, h BlobHTTPHeaders, ac BlobAccessConditions) (*BlobsPutResponse, error) {
 	ifModifiedSince, ifUnmodifiedSince, ifMatch, ifNoneMatch := ac.HTTPAccessConditions.pointers()
@@ -44,12 +61,31 @@ func (ab AppendBlobURL) Create(ctx context.Context, metadata Metadata, h BlobHTT
 		metadata, ac.LeaseAccessConditions.pointers(),
 		&h.ContentDisposition,
 		ifModifiedSince, ifUnmodifiedSince, ifMatch, ifNoneMatch, nil, nil, nil)
+}
 
+// CreateIfNotExists creates a 0-length append blob unless the blob already exists. created reports whether this call
+// created the blob; if the service reports ServiceCodeBlobAlreadyExists, CreateIfNotExists returns a nil response,
+// created=false and a nil error leaving the existing blob unchanged. Any other failure is returned as an error.
+// For more information, see https://docs.microsoft.com/rest/api/storageservices/put-blob.
+func (ab AppendBlobURL) CreateIfNotExists(ctx context.Context, metadata Metadata, h BlobHTTPHeaders) (resp *BlobsPutResponse, created bool, err error) {
+	resp, err = ab.Create(ctx, metadata, h, BlobAccessConditions{HTTPAccessConditions: HTTPAccessConditions{IfNoneMatch: ETagAny}})
+	if se, ok := err.(StorageError); ok && se.ServiceCode() == ServiceCodeBlobAlreadyExists {
+		return nil, false, nil
+	}
+	return resp, err == nil, err
 }
 
 // AppendBlock commits a new block of data to the end of the existing append blob.
+// The block's body must not exceed AppendBlobMaxAppendBlockBytes; if it does, ErrAppendBlockTooLarge is returned.
+// An append blob holds at most AppendBlobMaxBlocks blocks; use the response's RemainingBlockCount to see how many
+// more blocks can be appended.
 // For more information, see https://docs.microsoft.com/rest/api/storageservices/append-block.
 func (ab AppendBlobURL) AppendBlock(ctx context.Context, body io.ReadSeeker, ac BlobAccessConditions) (*AppendBlobsAppendBlockResponse, error) {
+	if size, err := remainingBytes(body); err != nil {
+		return nil, err
+	} else if size > AppendBlobMaxAppendBlockBytes {
+		return nil, ErrAppendBlockTooLarge
+	}
 	ifModifiedSince, ifUnmodifiedSince, ifMatchETag, ifNoneMatchETag := ac.HTTPAccessConditions.pointers()
 	ifAppendPositionEqual, ifMaxSizeLessThanOrEqual := ac.AppendBlobAccessConditions.pointers()
 	return ab.abClient.AppendBlock(ctx, body, nil, ac.LeaseAccessConditions.pointers(),
@@ -2,9 +2,15 @@ package azblob
 
 import (
 	"context"
+	"crypto/md5"
+	"errors"
 	"fmt"
+	"hash"
+	"io"
+	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/Azure/azure-pipeline-go/pipeline"
@@ -66,6 +72,27 @@ func (b BlobURL) ToPageBlobURL() PageBlobURL {
 	return NewPageBlobURL(b.URL(), b.blobClient.Pipeline())
 }
 
+// NewSASCopySourceURL returns source with a read-only blob SAS, signed by sharedKeyCredential and valid for validFor,
+// that can be passed to StartCopy (or CopyBlob) when the source blob is in another account or a private container.
+// The request log redacts the SAS' signature from the copy source. To use a SAS you already have, set the SAS field
+// of the source's BlobURLParts instead.
+func NewSASCopySourceURL(source url.URL, sharedKeyCredential *SharedKeyCredential, validFor time.Duration) url.URL {
+	if validFor <= 0 {
+		panic("validFor must be > 0")
+	}
+	parts := NewBlobURLParts(source)
+	if parts.BlobName == "" {
+		panic("source must be a blob's URL")
+	}
+	parts.SAS, _ = BlobSASSignatureValues{ // Permissions is set so this can't fail
+		ExpiryTime:    pkgClock.Now().UTC().Add(validFor),
+		Permissions:   BlobSASPermissions{Read: true}.String(),
+		ContainerName: parts.ContainerName,
+		BlobName:      parts.BlobName,
+	}.NewSASQueryParameters(sharedKeyCredential)
+	return parts.URL()
+}
+
 // StartCopy copies the data at the source URL to a blob.
 // For more information, see https://docs.microsoft.com/rest/api/storageservices/copy-blob.
 func (b BlobURL) StartCopy(ctx context.Context, source url.URL, metadata Metadata, srcac BlobAccessConditions, dstac BlobAccessConditions) (*BlobsCopyResponse, error) {
@@ -88,50 +115,341 @@ func (b BlobURL) AbortCopy(ctx context.Context, copyID string, ac LeaseAccessCon
 	return b.blobClient.AbortCopy(ctx, copyID, "abort", nil, ac.pointers(), nil)
 }
 
+// CopyFailedError is returned when a copy operation ends with a status other than CopyStatusSuccess or when
+// the blob's copy properties no longer describe the copy (because another copy or a write replaced it).
+type CopyFailedError struct {
+	CopyID            string
+	Status            CopyStatusType
+	StatusDescription string
+
+	// CurrentCopyID is the copy ID in the blob's properties; it differs from CopyID if the copy was replaced.
+	CurrentCopyID string
+}
+
+// Error implements the error interface's Error method to return a string representation of the error.
+func (e *CopyFailedError) Error() string {
+	if e.CurrentCopyID != e.CopyID {
+		return fmt.Sprintf("copy %s was replaced; the blob's current copy ID is %q", e.CopyID, e.CurrentCopyID)
+	}
+	return fmt.Sprintf("copy %s ended with status %s: %s", e.CopyID, e.Status, e.StatusDescription)
+}
+
+// PromoteSnapshot restores the base blob to the contents, properties, and metadata of the specified snapshot
+// by copying the snapshot over the base blob. It waits for the copy to complete and returns the base blob's new ETag.
+// srcac applies to the snapshot and dstac applies to the base blob. A copy that doesn't succeed returns a *CopyFailedError.
+// For more information, see https://docs.microsoft.com/rest/api/storageservices/copy-blob.
+func (b BlobURL) PromoteSnapshot(ctx context.Context, snapshot time.Time, srcac BlobAccessConditions, dstac BlobAccessConditions) (ETag, error) {
+	if snapshot.IsZero() {
+		panic("snapshot must not be the zero time")
+	}
+	base := b.WithSnapshot(time.Time{})
+	resp, err := base.StartCopy(ctx, b.WithSnapshot(snapshot).URL(), nil, srcac, dstac)
+	if err != nil {
+		return ETagNone, err
+	}
+	if resp.CopyStatus() == CopyStatusSuccess { // Copies within an account are usually synchronous
+		return resp.ETag(), nil
+	}
+	return base.WaitForCopy(ctx, resp.CopyID(), WaitForCopyOptions{})
+}
+
+// WaitForCopyOptions configures how WaitForCopy polls the blob's copy status.
+type WaitForCopyOptions struct {
+	// InitialInterval indicates the delay before the 2nd poll (0=default of 1 second).
+	InitialInterval time.Duration
+
+	// MaxInterval indicates the maximum delay between polls (0=default of 1 minute).
+	MaxInterval time.Duration
+
+	// Multiplier indicates how much the delay grows after each poll (0=default of 2).
+	Multiplier float64
+
+	// Progress, if not nil, is invoked after each poll with the bytes copied so far and the total bytes to copy.
+	Progress func(bytesCopied, totalBytes int64)
+}
+
+func (o WaitForCopyOptions) defaults() WaitForCopyOptions {
+	if o.InitialInterval < 0 || o.MaxInterval < 0 || o.Multiplier < 0 || (o.Multiplier != 0 && o.Multiplier < 1) {
+		panic("InitialInterval and MaxInterval must be >= 0 and Multiplier must be 0 or >= 1")
+	}
+	if o.InitialInterval == 0 {
+		o.InitialInterval = time.Second
+	}
+	if o.MaxInterval == 0 {
+		o.MaxInterval = time.Minute
+	}
+	if o.Multiplier == 0 {
+		o.Multiplier = 2
+	}
+	return o
+}
+
+// WaitForCopy polls the blob's properties until the specified copy is no longer pending and returns the blob's ETag.
+// A copy that doesn't succeed returns a *CopyFailedError, as does a copy that's been replaced by another copy (or whose
+// copy properties were cleared by a write to the blob). Use ctx to limit how long WaitForCopy waits.
+// For more information, see https://docs.microsoft.com/rest/api/storageservices/get-blob-properties.
+func (b BlobURL) WaitForCopy(ctx context.Context, copyID string, o WaitForCopyOptions) (ETag, error) {
+	o = o.defaults()
+	for interval := o.InitialInterval; ; {
+		props, err := b.GetPropertiesAndMetadata(ctx, BlobAccessConditions{})
+		if err != nil {
+			return ETagNone, err
+		}
+		if props.CopyID() != copyID {
+			return ETagNone, &CopyFailedError{CopyID: copyID, Status: props.CopyStatus(),
+				StatusDescription: props.CopyStatusDescription(), CurrentCopyID: props.CopyID()}
+		}
+		if o.Progress != nil {
+			if bytesCopied, totalBytes, ok := parseCopyProgress(props.CopyProgress()); ok {
+				o.Progress(bytesCopied, totalBytes)
+			}
+		}
+		switch props.CopyStatus() {
+		case CopyStatusSuccess:
+			return props.ETag(), nil
+		case CopyStatusPending:
+			select {
+			case <-ctx.Done():
+				return ETagNone, ctx.Err()
+			case <-pkgClock.After(interval):
+			}
+			if interval = time.Duration(float64(interval) * o.Multiplier); interval > o.MaxInterval {
+				interval = o.MaxInterval
+			}
+		default:
+			return ETagNone, &CopyFailedError{CopyID: copyID, Status: props.CopyStatus(),
+				StatusDescription: props.CopyStatusDescription(), CurrentCopyID: copyID}
+		}
+	}
+}
+
+// parseCopyProgress parses an x-ms-copy-progress value ("<bytes copied>/<total bytes>").
+func parseCopyProgress(progress string) (bytesCopied, totalBytes int64, ok bool) {
+	parts := strings.Split(progress, "/")
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+	var err1, err2 error
+	bytesCopied, err1 = strconv.ParseInt(parts[0], 10, 64)
+	totalBytes, err2 = strconv.ParseInt(parts[1], 10, 64)
+	return bytesCopied, totalBytes, err1 == nil && err2 == nil
+}
+
+// CountToEnd indicates that a BlobRange extends from its Offset to the end of the blob.
+const CountToEnd = 0
+
 // BlobRange defines a range of bytes within a blob, starting at Offset and ending
-// at Offset+Count. Use a zero-value BlobRange to indicate the entire blob.
+// at Offset+Count. Use a zero-value BlobRange to indicate the entire blob and a
+// Count of CountToEnd to indicate everything from Offset to the end of the blob.
+// The Blob service doesn't support suffix ranges ("bytes=-N") so Offset can't be negative;
+// to read the last N bytes of a blob, use DownloadTail.
 type BlobRange struct {
 	Offset int64
 	Count  int64
 }
 
-func (dr *BlobRange) pointers() *string {
+// Header returns the value of the Range/x-ms-range header for the range ("bytes=Offset-[Offset+Count-1]").
+// It returns "" for the entire blob and panics if Offset or Count is negative.
+func (dr BlobRange) Header() string {
 	if dr.Offset < 0 {
-		panic("The blob's range Offset must be >= 0")
+		panic("The blob's range Offset must be >= 0; to read the end of a blob, use DownloadTail")
 	}
 	if dr.Count < 0 {
 		panic("The blob's range Count must be >= 0")
 	}
-	if dr.Offset == 0 && dr.Count == 0 {
-		return nil
+	if dr.Offset == 0 && dr.Count == CountToEnd {
+		return ""
 	}
 	endRange := ""
-	if dr.Count > 0 {
+	if dr.Count != CountToEnd {
 		endRange = strconv.FormatInt((dr.Offset+dr.Count)-1, 10)
 	}
-	dataRange := fmt.Sprintf("bytes=%v-%s", dr.Offset, endRange)
+	return fmt.Sprintf("bytes=%v-%s", dr.Offset, endRange)
+}
+
+func (dr *BlobRange) pointers() *string {
+	dataRange := dr.Header()
+	if dataRange == "" {
+		return nil
+	}
 	return &dataRange
 }
 
+// BlobMaxRangeGetContentMD5Bytes indicates the largest range for which GetBlob can request the range's MD5.
+const BlobMaxRangeGetContentMD5Bytes = 4 * 1024 * 1024 // 4MB
+
+// ErrRangeTooLargeForMD5 is returned (without contacting the service) by GetBlob when rangeGetContentMD5 is true and
+// blobRange.Count isn't > 0 and <= BlobMaxRangeGetContentMD5Bytes.
+var ErrRangeTooLargeForMD5 = errors.New("rangeGetContentMD5 requires a blobRange.Count > 0 and <= BlobMaxRangeGetContentMD5Bytes")
+
 // GetBlob reads a range of bytes from a blob. The response also includes the blob's properties and metadata.
+// If rangeGetContentMD5 is true, blobRange.Count must be > 0 and <= BlobMaxRangeGetContentMD5Bytes (otherwise,
+// ErrRangeTooLargeForMD5 is returned); the service returns the range's MD5 and reading the response body returns a
+// *ChecksumMismatchError if the data doesn't match it.
+// If IfNoneMatch or IfModifiedSince indicate that the caller's copy is current, the service returns 304 (Not Modified);
+// GetBlob treats this as success and returns a response whose NotModified method returns true and whose body is empty.
+// The download helpers built on GetBlob (NewDownloadStream, NewSeekableDownloadStream) return the 304 as an error.
 // For more information, see https://docs.microsoft.com/rest/api/storageservices/get-blob.
 func (b BlobURL) GetBlob(ctx context.Context, blobRange BlobRange, ac BlobAccessConditions, rangeGetContentMD5 bool) (*GetResponse, error) {
 	var xRangeGetContentMD5 *bool
 	if rangeGetContentMD5 {
+		if blobRange.Count <= 0 || blobRange.Count > BlobMaxRangeGetContentMD5Bytes {
+			return nil, ErrRangeTooLargeForMD5
+		}
 		xRangeGetContentMD5 = &rangeGetContentMD5
 	}
 	ifModifiedSince, ifUnmodifiedSince, ifMatchETag, ifNoneMatchETag := ac.HTTPAccessConditions.pointers()
-	return b.blobClient.Get(ctx, nil, nil, blobRange.pointers(), ac.LeaseAccessConditions.pointers(), xRangeGetContentMD5,
+	resp, err := b.blobClient.Get(ctx, nil, nil, blobRange.pointers(), ac.LeaseAccessConditions.pointers(), xRangeGetContentMD5,
 		ifModifiedSince, ifUnmodifiedSince, ifMatchETag, ifNoneMatchETag, nil)
+	if serr, ok := err.(StorageError); ok && serr.Response() != nil && serr.Response().StatusCode == http.StatusNotModified {
+		r := serr.Response()
+		r.Body = http.NoBody // The responder already consumed the (empty) body
+		return &GetResponse{rawResponse: r}, nil
+	}
+	if err == nil && rangeGetContentMD5 && resp.rawResponse.Header.Get("Content-MD5") != "" {
+		resp.rawResponse.Body = &md5VerifyingBody{body: resp.rawResponse.Body, hash: md5.New(),
+			expected: resp.ContentMD5(), offset: blobRange.Offset, count: blobRange.Count}
+	}
+	return resp, err
+}
+
+// DownloadTail reads the last n bytes of the blob (or the whole blob if it is shorter than n bytes).
+// It gets the blob's length first and then reads the range from that version of the blob (using If-Match)
+// so the returned bytes are the tail even if the blob is appended to in between. The 2 requests are needed
+// because the Blob service doesn't support suffix ranges ("bytes=-N").
+// For more information, see https://docs.microsoft.com/rest/api/storageservices/get-blob.
+func (b BlobURL) DownloadTail(ctx context.Context, n int64, ac BlobAccessConditions) (*GetResponse, error) {
+	if n <= 0 {
+		panic("n must be > 0")
+	}
+	props, err := b.GetPropertiesAndMetadata(ctx, ac)
+	if err != nil {
+		return nil, err
+	}
+	offset := props.ContentLength() - n
+	if offset < 0 {
+		offset = 0
+	}
+	ac.IfMatch = props.ETag()
+	return b.GetBlob(ctx, BlobRange{Offset: offset, Count: CountToEnd}, ac, false)
+}
+
+// ChecksumMismatchError is returned when downloaded data doesn't match the MD5 returned by the service.
+type ChecksumMismatchError struct {
+	Offset   int64 // Offset of the range within the blob
+	Count    int64 // Number of bytes in the range
+	Expected [md5.Size]byte
+	Actual   [md5.Size]byte
+}
+
+// Error implements the error interface's Error method to return a string representation of the error.
+func (e *ChecksumMismatchError) Error() string {
+	return fmt.Sprintf("MD5 mismatch for range offset=%d, count=%d: expected %x, actual %x", e.Offset, e.Count, e.Expected, e.Actual)
+}
+
+// md5VerifyingBody hashes a response body as it is read and compares the hash to the expected MD5 at EOF.
+type md5VerifyingBody struct {
+	body          io.ReadCloser
+	hash          hash.Hash
+	expected      [md5.Size]byte
+	offset, count int64
+}
+
+func (b *md5VerifyingBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	b.hash.Write(p[:n])
+	if err == io.EOF {
+		var actual [md5.Size]byte
+		copy(actual[:], b.hash.Sum(nil))
+		if actual != b.expected {
+			return n, &ChecksumMismatchError{Offset: b.offset, Count: b.count, Expected: b.expected, Actual: actual}
+		}
+	}
+	return n, err
+}
+
+func (b *md5VerifyingBody) Close() error {
+	return b.body.Close()
 }
 
 // Delete marks the specified blob or snapshot for deletion. The blob is later deleted during garbage collection.
-// Note that deleting a blob also deletes all its snapshots.
+// A base blob that has snapshots can't be deleted by itself: pass DeleteSnapshotsOptionInclude to delete the blob
+// and its snapshots or DeleteSnapshotsOptionOnly to delete just its snapshots; with DeleteSnapshotsOptionNone,
+// the service fails the request with ServiceCodeSnapshotsPresent and Delete returns a *SnapshotsPresentError
+// explaining which option to pass. To delete a single snapshot, call Delete on
+// a URL with the snapshot's timestamp (see WithSnapshot) and pass DeleteSnapshotsOptionNone.
+// BlockBlobURL, AppendBlobURL and PageBlobURL all delete through this method.
 // For more information, see https://docs.microsoft.com/rest/api/storageservices/delete-blob.
 func (b BlobURL) Delete(ctx context.Context, deleteOptions DeleteSnapshotsOptionType, ac BlobAccessConditions) (*BlobsDeleteResponse, error) {
+	switch deleteOptions {
+	case DeleteSnapshotsOptionNone, DeleteSnapshotsOptionInclude, DeleteSnapshotsOptionOnly:
+	default:
+		panic("deleteOptions must be DeleteSnapshotsOptionNone, DeleteSnapshotsOptionInclude, or DeleteSnapshotsOptionOnly")
+	}
+	if deleteOptions != DeleteSnapshotsOptionNone && !NewBlobURLParts(b.URL()).Snapshot.IsZero() {
+		panic("deleteOptions must be DeleteSnapshotsOptionNone when deleting a snapshot")
+	}
 	ifModifiedSince, ifUnmodifiedSince, ifMatchETag, ifNoneMatchETag := ac.HTTPAccessConditions.pointers()
-	return b.blobClient.Delete(ctx, nil, nil, ac.LeaseAccessConditions.pointers(), deleteOptions,
+	resp, err := b.blobClient.Delete(ctx, nil, nil, ac.LeaseAccessConditions.pointers(), deleteOptions,
 		ifModifiedSince, ifUnmodifiedSince, ifMatchETag, ifNoneMatchETag, nil)
+	if se, ok := err.(StorageError); ok && se.ServiceCode() == ServiceCodeSnapshotsPresent {
+		return resp, &SnapshotsPresentError{StorageError: se}
+	}
+	return resp, err
+}
+
+// SnapshotsPresentError is returned by Delete when the base blob has snapshots and DeleteSnapshotsOptionNone was
+// passed. It holds the service's error (whose ServiceCode is ServiceCodeSnapshotsPresent).
+type SnapshotsPresentError struct {
+	StorageError
+}
+
+// Error implements the error interface's Error method to return a string representation of the error.
+func (e *SnapshotsPresentError) Error() string {
+	return "the blob has snapshots so it can't be deleted by itself; pass DeleteSnapshotsOptionInclude to delete " +
+		"the blob and its snapshots or DeleteSnapshotsOptionOnly to delete just its snapshots: " + e.StorageError.Error()
+}
+
+// Cause returns the service's error.
+func (e *SnapshotsPresentError) Cause() error { return e.StorageError }
+
+// DeleteSnapshots marks all of the blob's snapshots for deletion while keeping the base blob.
+// To delete the base blob and all its snapshots, call Delete with DeleteSnapshotsOptionInclude instead.
+// For more information, see https://docs.microsoft.com/rest/api/storageservices/delete-blob.
+func (b BlobURL) DeleteSnapshots(ctx context.Context, ac BlobAccessConditions) (*BlobsDeleteResponse, error) {
+	return b.WithSnapshot(time.Time{}).Delete(ctx, DeleteSnapshotsOptionOnly, ac)
+}
+
+// ListSnapshots returns the timestamps of the blob's snapshots in the order the service lists them (oldest first).
+// It lists the blob's container with the blob's name as a prefix and keeps only the snapshots of this blob.
+// For more information, see https://docs.microsoft.com/rest/api/storageservices/list-blobs.
+func (b BlobURL) ListSnapshots(ctx context.Context) ([]time.Time, error) {
+	p := NewBlobURLParts(b.URL())
+	blobName := p.BlobName
+	p.BlobName, p.Snapshot = "", time.Time{}
+	containerURL := NewContainerURL(p.URL(), b.blobClient.Pipeline())
+
+	snapshots := []time.Time{}
+	for marker := (Marker{}); marker.NotDone(); {
+		resp, err := containerURL.ListBlobs(ctx, marker, ListBlobsOptions{Prefix: blobName, Details: BlobListingDetails{Snapshots: true}})
+		if err != nil {
+			return nil, err
+		}
+		for _, blob := range resp.Blobs.Blob {
+			if blob.Name == blobName && !blob.Snapshot.IsZero() {
+				snapshots = append(snapshots, blob.Snapshot)
+			}
+		}
+		marker = resp.NextMarker
+	}
+	return snapshots, nil
+}
+
+// GetProperties returns the blob's properties. Use it for existence, length or ETag checks where the blob's
+// metadata isn't needed; it sends the same HEAD request as GetPropertiesAndMetadata.
+// For more information, see https://docs.microsoft.com/rest/api/storageservices/get-blob-properties.
+func (b BlobURL) GetProperties(ctx context.Context, ac BlobAccessConditions) (*BlobsGetPropertiesResponse, error) {
+	return b.GetPropertiesAndMetadata(ctx, ac)
 }
 
 // GetPropertiesAndMetadata returns the blob's metadata and properties.
@@ -161,6 +479,29 @@ func (b BlobURL) SetMetadata(ctx context.Context, metadata Metadata, ac BlobAcce
 		ifModifiedSince, ifUnmodifiedSince, ifMatchETag, ifNoneMatchETag, nil)
 }
 
+// MergeMetadata adds or updates the metadata keys in delta leaving the blob's other metadata keys unchanged;
+// a key with an empty value is removed. It reads the blob's metadata and then writes the merged metadata only
+// if the blob's ETag is unchanged. If another client modifies the blob in between, MergeMetadata returns a
+// StorageError with status 412 (Precondition Failed) instead of losing that update; call it again to retry.
+// For more information, see https://docs.microsoft.com/rest/api/storageservices/set-blob-metadata.
+func (b BlobURL) MergeMetadata(ctx context.Context, delta Metadata, ac BlobAccessConditions) (*BlobsSetMetadataResponse, error) {
+	props, err := b.GetPropertiesAndMetadata(ctx, ac)
+	if err != nil {
+		return nil, err
+	}
+	metadata := props.NewMetadata() // Keys are lowercase; metadata names are case-insensitive
+	for k, v := range delta {
+		k = strings.ToLower(k)
+		if v == "" {
+			delete(metadata, k)
+		} else {
+			metadata[k] = v
+		}
+	}
+	ac.IfMatch = props.ETag() // Guard against a concurrent update between our read and write
+	return b.SetMetadata(ctx, metadata, ac)
+}
+
 // CreateSnapshot creates a read-only snapshot of a blob.
 // For more information, see https://docs.microsoft.com/rest/api/storageservices/snapshot-blob.
 func (b BlobURL) CreateSnapshot(ctx context.Context, metadata Metadata, ac BlobAccessConditions) (*BlobsTakeSnapshotResponse, error) {
@@ -172,14 +513,23 @@ func (b BlobURL) CreateSnapshot(ctx context.Context, metadata Metadata, ac BlobA
 }
 
 // AcquireLease acquires a lease on the blob for write and delete operations. The lease duration must be between
-// 15 to 60 seconds, or infinite (-1).
+// 15 to 60 seconds, or infinite (LeaseInfinite); otherwise, ErrInvalidLeaseDuration is returned.
 // For more information, see https://docs.microsoft.com/rest/api/storageservices/lease-blob.
 func (b BlobURL) AcquireLease(ctx context.Context, proposedID string, duration int32, ac HTTPAccessConditions) (*BlobsLeaseResponse, error) {
+	if err := validateLeaseDuration(duration); err != nil {
+		return nil, err
+	}
 	ifModifiedSince, ifUnmodifiedSince, ifMatchETag, ifNoneMatchETag := ac.pointers()
 	return b.blobClient.Lease(ctx, LeaseActionAcquire, nil, nil, nil, &duration, &proposedID,
 		ifModifiedSince, ifUnmodifiedSince, ifMatchETag, ifNoneMatchETag, nil)
 }
 
+// AcquireInfiniteLease acquires a lease on the blob that never expires; call ReleaseLease (or BreakLease) to end it.
+// For more information, see https://docs.microsoft.com/rest/api/storageservices/lease-blob.
+func (b BlobURL) AcquireInfiniteLease(ctx context.Context, proposedID string, ac HTTPAccessConditions) (*BlobsLeaseResponse, error) {
+	return b.AcquireLease(ctx, proposedID, LeaseInfinite, ac)
+}
+
 // RenewLease renews the blob's previously-acquired lease.
 // For more information, see https://docs.microsoft.com/rest/api/storageservices/lease-blob.
 func (b BlobURL) RenewLease(ctx context.Context, leaseID string, ac HTTPAccessConditions) (*BlobsLeaseResponse, error) {
@@ -196,10 +546,16 @@ func (b BlobURL) ReleaseLease(ctx context.Context, leaseID string, ac HTTPAccess
 		ifModifiedSince, ifUnmodifiedSince, ifMatchETag, ifNoneMatchETag, nil)
 }
 
-// BreakLease breaks the blob's previously-acquired lease (if it exists). Pass the LeaseBreakDefault (-1) constant to break
-// a fixed-duration lease when it expires or an infinite lease immediately.
+// BreakLease breaks the blob's previously-acquired lease (if it exists). breakPeriodInSeconds (0 to 60) is how long the
+// lease continues before it is broken, giving its holder a grace period; 0 breaks it immediately. Pass the
+// LeaseBreakNaturally (-1) constant to break a fixed-duration lease when it expires or an infinite lease immediately.
+// Any other period returns ErrInvalidLeaseBreakPeriod. The response's LeaseTime is the number of seconds remaining
+// until the lease is broken.
 // For more information, see https://docs.microsoft.com/rest/api/storageservices/lease-blob.
 func (b BlobURL) BreakLease(ctx context.Context, leaseID string, breakPeriodInSeconds int32, ac HTTPAccessConditions) (*BlobsLeaseResponse, error) {
+	if err := validateLeaseBreakPeriod(breakPeriodInSeconds); err != nil {
+		return nil, err
+	}
 	ifModifiedSince, ifUnmodifiedSince, ifMatchETag, ifNoneMatchETag := ac.pointers()
 	return b.blobClient.Lease(ctx, LeaseActionBreak, nil, &leaseID, leasePeriodPointer(breakPeriodInSeconds), nil, nil,
 		ifModifiedSince, ifUnmodifiedSince, ifMatchETag, ifNoneMatchETag, nil)
@@ -216,9 +572,36 @@ func (b BlobURL) ChangeLease(ctx context.Context, leaseID string, proposedID str
 // LeaseBreakNaturally tells ContainerURL's or BlobURL's BreakLease method to break the lease using service semantics.
 const LeaseBreakNaturally = -1
 
+// LeaseInfinite tells ContainerURL's or BlobURL's AcquireLease method to acquire a lease that never expires.
+const LeaseInfinite = -1
+
+// ErrInvalidLeaseDuration is returned (without contacting the service) by ContainerURL's and BlobURL's AcquireLease
+// when the duration isn't LeaseInfinite or between 15 and 60 seconds.
+var ErrInvalidLeaseDuration = errors.New("the lease duration must be LeaseInfinite (-1) or between 15 and 60 seconds")
+
+// ErrInvalidLeaseBreakPeriod is returned (without contacting the service) by ContainerURL's and BlobURL's BreakLease
+// when the break period isn't LeaseBreakNaturally or between 0 and 60 seconds.
+var ErrInvalidLeaseBreakPeriod = errors.New("the lease break period must be LeaseBreakNaturally (-1) or between 0 and 60 seconds")
+
+// validateLeaseDuration returns ErrInvalidLeaseDuration unless duration is LeaseInfinite or between 15 and 60 seconds.
+func validateLeaseDuration(duration int32) error {
+	if duration != LeaseInfinite && (duration < 15 || duration > 60) {
+		return ErrInvalidLeaseDuration
+	}
+	return nil
+}
+
+// validateLeaseBreakPeriod returns ErrInvalidLeaseBreakPeriod unless period is LeaseBreakNaturally or between 0 and 60 seconds.
+func validateLeaseBreakPeriod(period int32) error {
+	if period != LeaseBreakNaturally && (period < 0 || period > 60) {
+		return ErrInvalidLeaseBreakPeriod
+	}
+	return nil
+}
+
 func leasePeriodPointer(period int32) (p *int32) {
 	if period != LeaseBreakNaturally {
 		p = &period
 	}
-	return nil
+	return p
 }
@@ -2,6 +2,10 @@ package azblob
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
 	"io"
 	"net/url"
 	"time"
@@ -17,6 +21,36 @@ const (
 	BlockBlobMaxBlocks = 50000
 )
 
+// ErrBlockTooLarge is returned (without contacting the service) by PutBlock when the block's body is larger than BlockBlobMaxPutBlockBytes.
+var ErrBlockTooLarge = errors.New("the block is larger than BlockBlobMaxPutBlockBytes")
+
+// ErrTooManyBlocks is returned by PutBlocksFromReader when the stream needs more blocks than its MaxBlockCount option
+// allows; no more blocks are read or sent.
+var ErrTooManyBlocks = errors.New("the stream needs more blocks than MaxBlockCount allows")
+
+// NewBlockID returns a base64-encoded block ID for the block at the specified index. All IDs returned
+// by NewBlockID have the same length as the service requires for all the block IDs of a blob.
+func NewBlockID(index int) string {
+	if index < 0 {
+		panic("index must be >= 0")
+	}
+	id := [8]byte{}
+	binary.BigEndian.PutUint64(id[:], uint64(index))
+	return base64.StdEncoding.EncodeToString(id[:])
+}
+
+// ParseBlockID returns the index of a block ID created by NewBlockID.
+func ParseBlockID(base64BlockID string) (int, error) {
+	id, err := base64.StdEncoding.DecodeString(base64BlockID)
+	if err != nil {
+		return 0, err
+	}
+	if len(id) != 8 {
+		return 0, fmt.Errorf("block ID %q was not created by NewBlockID", base64BlockID)
+	}
+	return int(binary.BigEndian.Uint64(id)), nil
+}
+
 // BlockBlobURL defines a set of operations applicable to block blobs.
 type BlockBlobURL struct {
 	BlobURL
@@ -60,14 +94,37 @@ func (bb BlockBlobURL) PutBlob(ctx context.Context, body io.ReadSeeker, h BlobHT
 }
 
 // GetBlockList returns the list of blocks that have been uploaded as part of a block blob using the specified block list filter.
+// If the URL has a snapshot timestamp (see WithSnapshot), the snapshot's committed block list is returned.
 // For more information, see https://docs.microsoft.com/rest/api/storageservices/get-block-list.
 func (bb BlockBlobURL) GetBlockList(ctx context.Context, listType BlockListType, ac LeaseAccessConditions) (*BlockList, error) {
 	return bb.bbClient.GetBlockList(ctx, listType, nil, nil, ac.pointers(), nil)
 }
 
+// HasUncommittedBlocks returns true if the blob has uncommitted blocks, such as those left by an upload that staged
+// blocks but never called PutBlockList. A blob with only uncommitted blocks doesn't exist for GetProperties (use
+// BlobListingDetails.UncommittedBlobs to list such blobs); HasUncommittedBlocks returns false if no blob exists.
+// To reclaim the blocks' space before the service discards them (after a week), commit an empty block list and
+// delete the blob.
+func (bb BlockBlobURL) HasUncommittedBlocks(ctx context.Context, ac LeaseAccessConditions) (bool, error) {
+	blockList, err := bb.GetBlockList(ctx, BlockListUncommitted, ac)
+	if err != nil {
+		if se, ok := err.(StorageError); ok && se.ServiceCode() == ServiceCodeBlobNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+	return len(blockList.UncommittedBlocks) > 0, nil
+}
+
 // PutBlock uploads the specified block to the block blob's "staging area" to be later commited by a call to PutBlockList.
+// The block's body (from its current position to its end) must not exceed BlockBlobMaxPutBlockBytes; if it does, ErrBlockTooLarge is returned.
 // For more information, see https://docs.microsoft.com/rest/api/storageservices/put-block.
 func (bb BlockBlobURL) PutBlock(ctx context.Context, base64BlockID string, body io.ReadSeeker, ac LeaseAccessConditions) (*BlockBlobsPutBlockResponse, error) {
+	if size, err := remainingBytes(body); err != nil {
+		return nil, err
+	} else if size > BlockBlobMaxPutBlockBytes {
+		return nil, ErrBlockTooLarge
+	}
 	return bb.bbClient.PutBlock(ctx, base64BlockID, body, nil, ac.pointers(), nil)
 }
 
@@ -85,3 +142,17 @@ func (bb BlockBlobURL) PutBlockList(ctx context.Context, base64BlockIDs []string
 		metadata, ac.LeaseAccessConditions.pointers(), &h.ContentDisposition,
 		ifModifiedSince, ifUnmodifiedSince, ifMatchETag, ifNoneMatchETag, nil)
 }
+
+// remainingBytes returns the number of bytes from the stream's current position to its end, leaving the position unchanged.
+func remainingBytes(body io.Seeker) (int64, error) {
+	current, err := body.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	end, err := body.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+	_, err = body.Seek(current, io.SeekStart)
+	return end - current, err
+}
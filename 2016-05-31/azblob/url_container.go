@@ -3,8 +3,13 @@ package azblob
 import (
 	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"net/http"
 	"net/url"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/Azure/azure-pipeline-go/pipeline"
 )
@@ -154,6 +159,45 @@ func (p *AccessPolicyPermission) Parse(s string) {
 	p.List = strings.ContainsRune(s, 'l')
 }
 
+// GetAnonymousAccess determines the container's effective public access level by reading it without credentials.
+// Call it on a ContainerURL whose pipeline uses an AnonymousCredential; with any other credential it reports the
+// credential's own access. It lists the container (which only PublicAccessContainer allows) and, if that's refused,
+// reads the properties of probeBlobName (which PublicAccessBlob allows). It returns PublicAccessNone if both are
+// refused or if probeBlobName is "" and listing is refused. An anonymous read of a blob that doesn't exist is
+// refused like a private one so probeBlobName should name an existing blob. Requests that fail for other reasons
+// return their error.
+func (c ContainerURL) GetAnonymousAccess(ctx context.Context, probeBlobName string) (PublicAccessType, error) {
+	_, err := c.ListBlobs(ctx, Marker{}, ListBlobsOptions{MaxResults: 1})
+	if err == nil {
+		return PublicAccessContainer, nil
+	}
+	if !isAccessRefused(err) {
+		return PublicAccessNone, err
+	}
+	if probeBlobName == "" {
+		return PublicAccessNone, nil
+	}
+	_, err = c.NewBlobURL(probeBlobName).GetPropertiesAndMetadata(ctx, BlobAccessConditions{})
+	if err == nil {
+		return PublicAccessBlob, nil
+	}
+	if !isAccessRefused(err) {
+		return PublicAccessNone, err
+	}
+	return PublicAccessNone, nil
+}
+
+// isAccessRefused returns true if err is the service refusing an anonymous request: it answers 404 (Not Found)
+// rather than 403 (Forbidden) for a private resource so as not to reveal that it exists.
+func isAccessRefused(err error) bool {
+	se, ok := err.(StorageError)
+	if !ok || se.Response() == nil || se.ServiceCode() == ServiceCodeContainerNotFound {
+		return false
+	}
+	statusCode := se.Response().StatusCode
+	return statusCode == http.StatusForbidden || statusCode == http.StatusNotFound
+}
+
 // SetPermissions sets the container's permissions. The permissions indicate whether blobs in a container may be accessed publicly.
 // For more information, see https://docs.microsoft.com/rest/api/storageservices/set-container-acl.
 func (c ContainerURL) SetPermissions(ctx context.Context, accessType PublicAccessType, permissions []SignedIdentifier,
@@ -162,14 +206,39 @@ func (c ContainerURL) SetPermissions(ctx context.Context, accessType PublicAcces
 	return c.client.SetACL(ctx, permissions, nil, nil, accessType, ifModifiedSince, ifUnmodifiedSince, ifMatchETag, ifNoneMatchETag, nil)
 }
 
-// AcquireLease acquires a lease on the container for delete operations. The lease duration must be between 15 to 60 seconds, or infinite (-1).
+// GetSASTokenWithPolicy returns a container SAS token (the query string to append to a URL after '?') that is governed
+// by the stored access policy named identifier. The token has no permissions or expiry of its own so changing or
+// removing the policy changes or revokes every SAS signed with it. The policy must already exist; see SetPermissions.
+func (c ContainerURL) GetSASTokenWithPolicy(identifier string, sharedKeyCredential *SharedKeyCredential) (string, error) {
+	if identifier == "" {
+		return "", errors.New("identifier must name an existing stored access policy")
+	}
+	parts := NewBlobURLParts(c.URL())
+	sas, err := BlobSASSignatureValues{ContainerName: parts.ContainerName, Identifier: identifier}.NewSASQueryParameters(sharedKeyCredential)
+	if err != nil {
+		return "", err
+	}
+	return sas.Encode(), nil
+}
+
+// AcquireLease acquires a lease on the container for delete operations. The lease duration must be between 15 to 60 seconds,
+// or infinite (LeaseInfinite); otherwise, ErrInvalidLeaseDuration is returned.
 // For more information, see https://docs.microsoft.com/rest/api/storageservices/lease-container.
 func (c ContainerURL) AcquireLease(ctx context.Context, proposedID string, duration int32, ac HTTPAccessConditions) (*ContainerLeaseResponse, error) {
+	if err := validateLeaseDuration(duration); err != nil {
+		return nil, err
+	}
 	ifModifiedSince, ifUnmodifiedSince, _, _ := ac.pointers()
 	return c.client.Lease(ctx, LeaseActionAcquire, nil, nil, nil, &duration, &proposedID,
 		ifModifiedSince, ifUnmodifiedSince, nil)
 }
 
+// AcquireInfiniteLease acquires a lease on the container that never expires; call ReleaseLease (or BreakLease) to end it.
+// For more information, see https://docs.microsoft.com/rest/api/storageservices/lease-container.
+func (c ContainerURL) AcquireInfiniteLease(ctx context.Context, proposedID string, ac HTTPAccessConditions) (*ContainerLeaseResponse, error) {
+	return c.AcquireLease(ctx, proposedID, LeaseInfinite, ac)
+}
+
 // RenewLease renews the container's previously-acquired lease.
 // For more information, see https://docs.microsoft.com/rest/api/storageservices/lease-container.
 func (c ContainerURL) RenewLease(ctx context.Context, leaseID string, ac HTTPAccessConditions) (*ContainerLeaseResponse, error) {
@@ -184,9 +253,14 @@ func (c ContainerURL) ReleaseLease(ctx context.Context, leaseID string, ac HTTPA
 	return c.client.Lease(ctx, LeaseActionRelease, nil, &leaseID, nil, nil, nil, ifModifiedSince, ifUnmodifiedSince, nil)
 }
 
-// BreakLease breaks the container's previously-acquired lease (if it exists).
+// BreakLease breaks the container's previously-acquired lease (if it exists). period (0 to 60 seconds, or
+// LeaseBreakNaturally) works as it does for BlobURL's BreakLease; the response's LeaseTime is the number of
+// seconds remaining until the lease is broken.
 // For more information, see https://docs.microsoft.com/rest/api/storageservices/lease-container.
 func (c ContainerURL) BreakLease(ctx context.Context, leaseID string, period int32, ac HTTPAccessConditions) (*ContainerLeaseResponse, error) {
+	if err := validateLeaseBreakPeriod(period); err != nil {
+		return nil, err
+	}
 	ifModifiedSince, ifUnmodifiedSince, _, _ := ac.pointers()
 	return c.client.Lease(ctx, LeaseActionBreak, nil, &leaseID, leasePeriodPointer(period), nil, nil, ifModifiedSince, ifUnmodifiedSince, nil)
 }
@@ -201,13 +275,88 @@ func (c ContainerURL) ChangeLease(ctx context.Context, leaseID string, proposedI
 // ListBlobs returns a single segment of blobs starting from the specified Marker. Use an empty
 // Marker to start enumeration from the beginning. Blob names are returned in lexicographic order.
 // After getting a segment, process it, and then call ListBlobs again (passing the the previously-returned
-// Marker) to get the next segment.
+// Marker) to get the next segment. The same ListBlobsOptions serve flat listings (no Delimiter) and
+// hierarchical listings (with a Delimiter, the "directories" are returned as BlobPrefix entries).
 // For more information, see https://docs.microsoft.com/rest/api/storageservices/list-blobs.
 func (c ContainerURL) ListBlobs(ctx context.Context, marker Marker, o ListBlobsOptions) (*ListBlobsResponse, error) {
 	prefix, delimiter, include, maxResults := o.pointers()
 	return c.client.ListBlobs(ctx, prefix, delimiter, marker.val, maxResults, include, nil, nil)
 }
 
+// BlobErrors maps the names of blobs to the errors returned by an operation on each of them.
+type BlobErrors map[string]error
+
+// Error implements the error interface's Error method to return a string representation of the errors.
+func (e BlobErrors) Error() string {
+	names := make([]string, 0, len(e))
+	for name := range e {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	b := &bytes.Buffer{}
+	fmt.Fprintf(b, "%d blob operation(s) failed:\n", len(e))
+	for _, name := range names {
+		fmt.Fprintf(b, "   %s: %v\n", name, e[name])
+	}
+	return b.String()
+}
+
+// SetMetadataForPrefix sets the metadata of every blob whose name starts with prefix, with up to parallelism
+// SetMetadata calls in flight. A failure for one blob doesn't stop the others; if any fail, SetMetadataForPrefix
+// returns a BlobErrors identifying them. If listing the blobs fails or ctx is done, that error is returned instead
+// and blobs not yet reached are left unchanged.
+// For more information, see https://docs.microsoft.com/rest/api/storageservices/set-blob-metadata.
+func (c ContainerURL) SetMetadataForPrefix(ctx context.Context, prefix string, metadata Metadata, parallelism int) error {
+	if parallelism <= 0 {
+		panic("parallelism must be > 0")
+	}
+	names := make(chan string)
+	errs := BlobErrors{}
+	errsLock := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	for g := 0; g < parallelism; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range names {
+				if _, err := c.NewBlobURL(name).SetMetadata(ctx, metadata, BlobAccessConditions{}); err != nil {
+					errsLock.Lock()
+					errs[name] = err
+					errsLock.Unlock()
+				}
+			}
+		}()
+	}
+
+	var listErr error
+list:
+	for marker := (Marker{}); marker.NotDone(); {
+		resp, err := c.ListBlobs(ctx, marker, ListBlobsOptions{Prefix: prefix})
+		if err != nil {
+			listErr = err
+			break
+		}
+		marker = resp.NextMarker
+		for _, blob := range resp.Blobs.Blob {
+			select {
+			case names <- blob.Name:
+			case <-ctx.Done():
+				listErr = ctx.Err()
+				break list
+			}
+		}
+	}
+	close(names)
+	wg.Wait()
+	if listErr != nil {
+		return listErr
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
 // ListBlobsOptions defines options available when calling ListBlobs.
 type ListBlobsOptions struct {
 	Details   BlobListingDetails // No IncludeType header is produced if ""
@@ -237,9 +386,13 @@ func (o *ListBlobsOptions) pointers() (prefix *string, delimiter *string, includ
 	return
 }
 
-// BlobListingDetails indicates what additional information teh service should return with each blob.
+// BlobListingDetails indicates what additional information the service should return with each blob.
+// Each field that is true adds its value to the comma-separated "include" query parameter.
 type BlobListingDetails struct {
-	Copy, Metadata, Snapshots, UncommittedBlobs bool
+	Copy             bool // Return each blob's Copy* properties (CopyID, CopyStatus, CopyProgress, ...)
+	Metadata         bool // Return each blob's Metadata
+	Snapshots        bool // Return snapshots (their Snapshot is non-zero) along with the base blobs
+	UncommittedBlobs bool // Return blobs that only have uncommitted blocks
 }
 
 // string produces the Include query parameter's value.
@@ -2,10 +2,8 @@ package azblob
 
 import (
 	"context"
-	"fmt"
 	"io"
 	"net/url"
-	"strconv"
 	"time"
 
 	"github.com/Azure/azure-pipeline-go/pipeline"
@@ -154,8 +152,7 @@ func (pr PageRange) pointers() *string {
 	if pr.End <= pr.Start {
 		panic("PageRange's End value must be after the start")
 	}
-	endOffset := strconv.FormatInt(int64(pr.End), 10)
-	asString := fmt.Sprintf("bytes=%v-%s", pr.Start, endOffset)
+	asString := BlobRange{Offset: int64(pr.Start), Count: int64(pr.End-pr.Start) + 1}.Header()
 	return &asString
 }
 
@@ -2,8 +2,11 @@ package azblob
 
 import (
 	"context"
+	"log"
+	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/Azure/azure-pipeline-go/pipeline"
 )
@@ -21,11 +24,67 @@ type PipelineOptions struct {
 	// Retry configures the built-in retry policy behavior.
 	Retry RetryOptions
 
+	// Failover, if not nil, sends reads to the secondary endpoint while the primary is timing out.
+	// See NewFailoverPolicyFactory.
+	Failover *FailoverPolicyFactory
+
+	// CircuitBreaker, if not nil, fails requests fast with ErrCircuitOpen while the service is unhealthy. Share one
+	// across pipelines to stop them all from sending to a failing service. See NewCircuitBreakerPolicyFactory.
+	CircuitBreaker *CircuitBreakerPolicyFactory
+
 	// RequestLog configures the built-in request logging policy.
 	RequestLog RequestLogOptions
 
 	// Telemetry configures the built-in telemetry policy behavior.
 	Telemetry TelemetryOptions
+
+	// ExpectContinue configures the opt-in Expect-100-continue policy.
+	ExpectContinue ExpectContinueOptions
+
+	// HTTPSender configures the factory whose policy sends HTTP requests over the network (nil=default sender).
+	// For example, see NewDNSCacheHTTPSenderFactory and NewDualStackHTTPSenderFactory.
+	HTTPSender pipeline.Factory
+
+	// MaxConcurrentRequests limits the number of requests the pipeline has in flight at once (0=unlimited).
+	// Requests beyond the limit wait for a slot; the wait doesn't count against RetryOptions.TryTimeout (or the
+	// server-side timeout) and a request keeps its slot across its retries. See ConcurrencyLimiter.
+	MaxConcurrentRequests int
+
+	// ConcurrencyLimiter, if not nil, is used instead of MaxConcurrentRequests. Use it to share a limit
+	// across pipelines or to read the number of requests in flight.
+	ConcurrencyLimiter *ConcurrencyLimiter
+
+	// RequestHook, if not nil, is invoked with every try's HTTP request before it is signed and sent.
+	RequestHook func(*http.Request)
+
+	// ResponseHook, if not nil, is invoked with every try's HTTP response (nil if there is none) and error.
+	ResponseHook func(*http.Response, error)
+}
+
+// DefaultPipelineOptions returns the PipelineOptions used by NewDefaultPipeline: exponential retries (4 tries with a
+// 30-second try timeout and no reads from a secondary host), a warning for any try taking longer than 3 seconds, and
+// warnings & errors written to Go's standard logger. To override individual fields, change them in the returned
+// value and pass it to NewPipeline; for example, raise Retry.TryTimeout when transferring large blobs.
+func DefaultPipelineOptions() PipelineOptions {
+	return PipelineOptions{
+		Retry: RetryOptions{
+			Policy:        RetryPolicyExponential,
+			MaxTries:      4,
+			TryTimeout:    30 * time.Second,
+			RetryDelay:    4 * time.Second,
+			MaxRetryDelay: 120 * time.Second,
+		},
+		RequestLog: RequestLogOptions{LogWarningIfTryOverThreshold: 3 * time.Second},
+		Log: pipeline.LogOptions{
+			Log:                  func(s pipeline.LogSeverity, m string) { log.Output(2, m) },
+			MinimumSeverityToLog: func() pipeline.LogSeverity { return pipeline.LogWarning },
+		},
+	}
+}
+
+// NewDefaultPipeline creates a Pipeline using the specified credentials and DefaultPipelineOptions.
+func NewDefaultPipeline(c Credential) pipeline.Pipeline {
+	return NewPipeline(c, DefaultPipelineOptions())
 }
 
 // NewPipeline creates a Pipeline using the specified credentials and options.
@@ -38,7 +97,27 @@ func NewPipeline(c Credential, o PipelineOptions) pipeline.Pipeline {
 	f := []pipeline.Factory{
 		NewTelemetryPolicyFactory(o.Telemetry),
 		NewUniqueRequestIDPolicyFactory(),
-		NewRetryPolicyFactory(o.Retry),
+		NewServiceVersionPolicyFactory(),
+	}
+	if o.ConcurrencyLimiter == nil && o.MaxConcurrentRequests > 0 {
+		o.ConcurrencyLimiter = NewConcurrencyLimiter(o.MaxConcurrentRequests)
+	}
+	if o.ConcurrencyLimiter != nil {
+		// Before the retry policy so time spent waiting for a slot doesn't count against a try's TryTimeout
+		f = append(f, o.ConcurrencyLimiter)
+	}
+	f = append(f, NewRetryPolicyFactory(o.Retry))
+	if o.CircuitBreaker != nil {
+		f = append(f, o.CircuitBreaker) // After the retry policy so every try is counted
+	}
+	if o.Failover != nil {
+		f = append(f, o.Failover)
+	}
+	if o.RequestHook != nil || o.ResponseHook != nil {
+		f = append(f, NewHookPolicyFactory(o.RequestHook, o.ResponseHook))
+	}
+	if o.ExpectContinue.Enabled {
+		f = append(f, NewExpectContinuePolicyFactory(o.ExpectContinue))
 	}
 	if _, ok := c.(*anonymousCredentialPolicyFactory); !ok {
 		// For AnonymousCredential, we optimize out the policy factory since it doesn't do anything
@@ -48,9 +127,11 @@ func NewPipeline(c Credential, o PipelineOptions) pipeline.Pipeline {
 	}
 	f = append(f,
 		pipeline.MethodFactoryMarker(), // indicates at what stage in the pipeline the method factory is invoked
+		newErrorResponseBodyPolicyFactory(),
+		NewTransferCounterPolicyFactory(),
 		NewRequestLogPolicyFactory(o.RequestLog))
 
-	return pipeline.NewPipeline(f, pipeline.Options{HTTPSender: nil, Log: o.Log})
+	return pipeline.NewPipeline(f, pipeline.Options{HTTPSender: o.HTTPSender, Log: o.Log})
 }
 
 // A ServiceURL represents a URL to the Azure Storage Blob service allowing you to manipulate blob containers.
@@ -119,6 +200,7 @@ func appendToURLPath(u url.URL, name string) url.URL {
 		u.Path += "/" // Append "/" to end before appending name
 	}
 	u.Path += name
+	u.RawPath = "" // Any escaping of the old path no longer matches Path; let url.URL escape the new path
 	return u
 }
 
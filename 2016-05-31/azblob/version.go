@@ -1,4 +1,4 @@
 package azblob
 
-const serviceLibVersion = "0.1"
-
+// SDKVersion is this package's version. It is sent in each request's User-Agent (see NewTelemetryPolicyFactory).
+const SDKVersion = "0.1"
@@ -0,0 +1,61 @@
+package azblob_test
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"time"
+
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/2016-05-31/azblob"
+)
+
+// skewedService returns a fakePolicy that acts like a service whose clock is skew ahead of the local clock: a request
+// whose x-ms-date is more than 15 minutes off fails authentication. *tries counts the requests.
+func skewedService(skew time.Duration, tries *int) fakePolicy {
+	return func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+		*tries++
+		now := time.Now().Add(skew)
+		header := http.Header{}
+		header.Set("Date", now.UTC().Format(http.TimeFormat))
+		sent, err := http.ParseTime(request.Header.Get("x-ms-date"))
+		if err != nil || now.Sub(sent) > 15*time.Minute || sent.Sub(now) > 15*time.Minute {
+			body := "<?xml version=\"1.0\" encoding=\"utf-8\"?><Error><Code>AuthenticationFailed</Code><Message>Request date header too old</Message></Error>"
+			return newFakeResponse(request, http.StatusForbidden, header, body), nil
+		}
+		return newFakeResponse(request, http.StatusAccepted, header, ""), nil
+	}
+}
+
+func (s *aztestsSuite) TestSharedKeyCredentialCorrectsClockSkew(c *chk.C) {
+	u, _ := url.Parse("http://account.blob.core.windows.net/container/blob")
+	credential := azblob.NewSharedKeyCredential("account", base64.StdEncoding.EncodeToString([]byte("key")))
+	tries := 0
+	service := skewedService(time.Hour, &tries)
+	p := pipeline.NewPipeline([]pipeline.Factory{credential, pipeline.MethodFactoryMarker(), service}, pipeline.Options{})
+	blobURL := azblob.NewBlobURL(*u, p)
+
+	// The 1st try fails authentication; the credential learns the skew and its retry succeeds
+	_, err := blobURL.Delete(context.Background(), azblob.DeleteSnapshotsOptionNone, azblob.BlobAccessConditions{})
+	c.Assert(err, chk.IsNil)
+	c.Assert(tries, chk.Equals, 2)
+	c.Assert(credential.ClockSkew() > 59*time.Minute && credential.ClockSkew() < 61*time.Minute, chk.Equals, true)
+
+	// Later requests are sent with the corrected x-ms-date
+	_, err = blobURL.Delete(context.Background(), azblob.DeleteSnapshotsOptionNone, azblob.BlobAccessConditions{})
+	c.Assert(err, chk.IsNil)
+	c.Assert(tries, chk.Equals, 3)
+
+	// A configured skew is used from the 1st request
+	credential = azblob.NewSharedKeyCredential("account", base64.StdEncoding.EncodeToString([]byte("key")))
+	credential.SetClockSkew(-time.Hour)
+	tries = 0
+	service = skewedService(-time.Hour, &tries)
+	p = pipeline.NewPipeline([]pipeline.Factory{credential, pipeline.MethodFactoryMarker(), service}, pipeline.Options{})
+	_, err = blobURL.WithPipeline(p).Delete(context.Background(), azblob.DeleteSnapshotsOptionNone, azblob.BlobAccessConditions{})
+	c.Assert(err, chk.IsNil)
+	c.Assert(tries, chk.Equals, 1)
+}
@@ -318,7 +318,7 @@ func ExampleBlobSASSignatureValues() {
 	blobName := "HelloWorld.txt"   // Blob names can be mixed case
 
 	// Set the desired SAS signature values and sign them with the shared key credentials to get the SAS query parameters.
-	sasQueryParams := BlobSASSignatureValues{
+	sasQueryParams, err := BlobSASSignatureValues{
 		Protocol:      SASProtocolHTTPS,               // Users MUST use HTTPS (not HTTP)
 		ExpiryTime:    time.Now().Add(48 * time.Hour), // 48-hours before expiration
 		ContainerName: containerName,
@@ -328,6 +328,9 @@ func ExampleBlobSASSignatureValues() {
 		// ContainerSASPermissions and make sure the BlobName field is "" (the default).
 		Permissions: BlobSASPermissions{Add: true, Read: true, Write: true}.String(),
 	}.NewSASQueryParameters(credential)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	// Create the URL of the resource you wish to access and append the SAS query parameters.
 	// Since this is a blob SAS, the URL is to the Azure storage blob.
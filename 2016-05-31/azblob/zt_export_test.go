@@ -0,0 +1,39 @@
+package azblob
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// This file exposes unexported hooks to the azblob_test package; it is only compiled by "go test".
+
+type testClock struct {
+	now   func() time.Time
+	after func(d time.Duration) <-chan time.Time
+}
+
+func (c testClock) Now() time.Time                         { return c.now() }
+func (c testClock) After(d time.Duration) <-chan time.Time { return c.after(d) }
+
+// SetClockForTesting replaces the package's clock with the passed-in functions; a nil function defers to the
+// real clock. The returned func restores the previous clock. Tests that call this must not run in parallel.
+func SetClockForTesting(now func() time.Time, after func(d time.Duration) <-chan time.Time) (restore func()) {
+	if now == nil {
+		now = time.Now
+	}
+	if after == nil {
+		after = time.After
+	}
+	previous := pkgClock
+	pkgClock = testClock{now: now, after: after}
+	return func() { pkgClock = previous }
+}
+
+// NewDNSCacheDialerForTesting returns the DialContext function used by NewDNSCacheHTTPSenderFactory with the cache's
+// connections made by dial instead of a net.Dialer.
+func NewDNSCacheDialerForTesting(o DNSCacheOptions,
+	dial func(ctx context.Context, network, address string) (net.Conn, error)) func(ctx context.Context, network, address string) (net.Conn, error) {
+	cache := &dnsCache{o: o.defaults(), entries: map[string]*dnsCacheEntry{}}
+	return cache.dialContext(dial)
+}
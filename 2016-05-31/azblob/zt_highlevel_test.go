@@ -0,0 +1,776 @@
+package azblob_test
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/2016-05-31/azblob"
+)
+
+func (s *aztestsSuite) TestRetryableBodyInMemory(c *chk.C) {
+	data := []byte("RetryableBodyData")
+	body, err := azblob.NewRetryableBody(bytes.NewBuffer(data), azblob.RetryableBodyOptions{})
+	c.Assert(err, chk.IsNil)
+	defer body.Close()
+
+	for i := 0; i < 2; i++ { // The body can be replayed
+		_, err = body.Seek(0, io.SeekStart)
+		c.Assert(err, chk.IsNil)
+		read, err := ioutil.ReadAll(body)
+		c.Assert(err, chk.IsNil)
+		c.Assert(read, chk.DeepEquals, data)
+	}
+}
+
+func (s *aztestsSuite) TestRetryableBodyTooLargeWithoutSpill(c *chk.C) {
+	_, err := azblob.NewRetryableBody(bytes.NewBuffer(make([]byte, 11)), azblob.RetryableBodyOptions{MaxMemoryBytes: 10})
+	c.Assert(err, chk.NotNil)
+}
+
+func (s *aztestsSuite) TestRetryableBodySpillToFile(c *chk.C) {
+	dir, err := ioutil.TempDir("", "azblob")
+	c.Assert(err, chk.IsNil)
+	defer os.RemoveAll(dir)
+
+	data := []byte("RetryableBodyDataLongerThanTheMemoryLimit")
+	body, err := azblob.NewRetryableBody(bytes.NewBuffer(data),
+		azblob.RetryableBodyOptions{MaxMemoryBytes: 10, SpillToFile: true, TempDir: dir})
+	c.Assert(err, chk.IsNil)
+
+	read, err := ioutil.ReadAll(body)
+	c.Assert(err, chk.IsNil)
+	c.Assert(read, chk.DeepEquals, data)
+
+	files, _ := ioutil.ReadDir(dir)
+	c.Assert(files, chk.HasLen, 1) // The stream was spilled to a temporary file
+	c.Assert(body.Close(), chk.IsNil)
+	files, _ = ioutil.ReadDir(dir)
+	c.Assert(files, chk.HasLen, 0) // Close removed the temporary file
+}
+
+func (s *aztestsSuite) TestDownloadBlobToFile(c *chk.C) {
+	bsu := getBSU()
+	containerURL, _ := createNewContainer(c, bsu)
+	defer deleteContainer(c, containerURL)
+	blobURL, _ := getBlockBlobURL(c, containerURL)
+
+	_, data := getRandomDataAndReader(10 * 1024)
+	_, err := blobURL.PutBlob(context.Background(), bytes.NewReader(data), azblob.BlobHTTPHeaders{}, nil, azblob.BlobAccessConditions{})
+	c.Assert(err, chk.IsNil)
+
+	dir, err := ioutil.TempDir("", "azblob")
+	c.Assert(err, chk.IsNil)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "blob")
+
+	_, err = azblob.DownloadBlobToFile(context.Background(), blobURL.BlobURL, path,
+		azblob.DownloadBlobToFileOptions{BlockSize: 1024, Parallelism: 3})
+	c.Assert(err, chk.IsNil)
+	downloaded, err := ioutil.ReadFile(path)
+	c.Assert(err, chk.IsNil)
+	c.Assert(downloaded, chk.DeepEquals, data)
+	_, err = os.Stat(path + ".part")
+	c.Assert(os.IsNotExist(err), chk.Equals, true) // The temporary file was renamed
+}
+
+// rangeServer returns a fakePolicy that serves ranged GETs of data (replying 416 to any range of an empty blob) and
+// appends each request's method to methods.
+func rangeServer(data []byte, methods *[]string) fakePolicy {
+	lock := sync.Mutex{}
+	return func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+		lock.Lock()
+		*methods = append(*methods, request.Method)
+		lock.Unlock()
+		sum := md5.Sum(data)
+		header := http.Header{"Etag": []string{`"v1"`}, "X-Ms-Blob-Content-Md5": []string{base64.StdEncoding.EncodeToString(sum[:])}}
+		var first, last int64
+		if _, err := fmt.Sscanf(request.Header.Get("x-ms-range"), "bytes=%d-%d", &first, &last); err != nil {
+			header.Set("Content-MD5", header.Get("X-Ms-Blob-Content-Md5"))
+			header.Set("Content-Length", strconv.Itoa(len(data)))
+			return newFakeResponse(request, http.StatusOK, header, string(data)), nil
+		}
+		if first >= int64(len(data)) {
+			return newFakeResponse(request, http.StatusRequestedRangeNotSatisfiable, header,
+				"<?xml version=\"1.0\" encoding=\"utf-8\"?><Error><Code>InvalidRange</Code></Error>"), nil
+		}
+		if last >= int64(len(data)) {
+			last = int64(len(data)) - 1
+		}
+		header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", first, last, len(data)))
+		header.Set("Content-Length", strconv.FormatInt(last-first+1, 10))
+		return newFakeResponse(request, http.StatusPartialContent, header, string(data[first:last+1])), nil
+	}
+}
+
+func (s *aztestsSuite) TestDownloadBlobToFileSizesFromFirstRange(c *chk.C) {
+	dir, err := ioutil.TempDir("", "azblob")
+	c.Assert(err, chk.IsNil)
+	defer os.RemoveAll(dir)
+	u, _ := url.Parse("http://account.blob.core.windows.net/container/blob")
+
+	for _, size := range []int{0, 1000, 10*1024 + 1} {
+		_, data := getRandomDataAndReader(size)
+		methods := []string{}
+		service := rangeServer(data, &methods)
+		blobURL := azblob.NewBlobURL(*u, pipeline.NewPipeline([]pipeline.Factory{pipeline.MethodFactoryMarker(), service}, pipeline.Options{}))
+		filePath := filepath.Join(dir, fmt.Sprintf("blob%d", size))
+		progress := []int64{} // Appended to without a lock since Progress calls are serialized
+
+		resp, err := azblob.DownloadBlobToFile(context.Background(), blobURL, filePath,
+			azblob.DownloadBlobToFileOptions{BlockSize: 1024, Parallelism: 3, Progress: func(bytesTransferred int64) {
+				progress = append(progress, bytesTransferred)
+			}})
+		c.Assert(err, chk.IsNil)
+		c.Assert(resp.BlobContentLength(), chk.Equals, int64(size))
+		c.Assert(resp.ETag(), chk.Equals, azblob.ETag(`"v1"`))
+		downloaded, err := ioutil.ReadFile(filePath)
+		c.Assert(err, chk.IsNil)
+		c.Assert(downloaded, chk.HasLen, size)
+		c.Assert(bytes.Equal(downloaded, data), chk.Equals, true)
+		for _, method := range methods {
+			c.Assert(method, chk.Equals, http.MethodGet) // The size didn't need a GetProperties (HEAD) request
+		}
+		c.Assert(len(methods) >= (size+1023)/1024, chk.Equals, true)
+		c.Assert(progress, chk.HasLen, (size+1023)/1024)
+		for i := range progress {
+			c.Assert(i == 0 || progress[i] > progress[i-1], chk.Equals, true)
+		}
+		if size > 0 {
+			c.Assert(progress[len(progress)-1], chk.Equals, int64(size))
+		}
+	}
+}
+
+func (s *aztestsSuite) TestDownloadBlobToFileVerifyChunkMD5(c *chk.C) {
+	bsu := getBSU()
+	containerURL, _ := createNewContainer(c, bsu)
+	defer deleteContainer(c, containerURL)
+	blobURL, _ := getBlockBlobURL(c, containerURL)
+
+	_, data := getRandomDataAndReader(10*1024 + 1)
+	_, err := blobURL.PutBlob(context.Background(), bytes.NewReader(data), azblob.BlobHTTPHeaders{}, nil, azblob.BlobAccessConditions{})
+	c.Assert(err, chk.IsNil)
+
+	dir, err := ioutil.TempDir("", "azblob")
+	c.Assert(err, chk.IsNil)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "blob")
+
+	_, err = azblob.DownloadBlobToFile(context.Background(), blobURL.BlobURL, path,
+		azblob.DownloadBlobToFileOptions{BlockSize: 1024, Parallelism: 3, VerifyChunkMD5: true})
+	c.Assert(err, chk.IsNil)
+	downloaded, err := ioutil.ReadFile(path)
+	c.Assert(err, chk.IsNil)
+	c.Assert(downloaded, chk.DeepEquals, data)
+
+	c.Assert(func() {
+		azblob.DownloadBlobToFile(context.Background(), blobURL.BlobURL, path,
+			azblob.DownloadBlobToFileOptions{BlockSize: azblob.BlobMaxRangeGetContentMD5Bytes + 1, VerifyChunkMD5: true})
+	}, chk.Panics, fmt.Sprintf("BlockSize must be <= %d when VerifyChunkMD5 is true", azblob.BlobMaxRangeGetContentMD5Bytes))
+}
+
+func (s *aztestsSuite) TestDownloadBlobToFileFailureLeavesNoFile(c *chk.C) {
+	bsu := getBSU()
+	containerURL, _ := createNewContainer(c, bsu)
+	defer deleteContainer(c, containerURL)
+	blobURL, _ := getBlockBlobURL(c, containerURL) // The blob doesn't exist
+
+	dir, err := ioutil.TempDir("", "azblob")
+	c.Assert(err, chk.IsNil)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "blob")
+
+	_, err = azblob.DownloadBlobToFile(context.Background(), blobURL.BlobURL, path, azblob.DownloadBlobToFileOptions{})
+	c.Assert(err, chk.NotNil)
+	files, _ := ioutil.ReadDir(dir)
+	c.Assert(files, chk.HasLen, 0)
+}
+
+func (s *aztestsSuite) TestDownloadStreamDecompressResponse(c *chk.C) {
+	bsu := getBSU()
+	containerURL, _ := createNewContainer(c, bsu)
+	defer deleteContainer(c, containerURL)
+	blobURL, _ := getBlockBlobURL(c, containerURL)
+
+	compressed := &bytes.Buffer{}
+	gz := gzip.NewWriter(compressed)
+	gz.Write([]byte(blockBlobDefaultData))
+	gz.Close()
+	_, err := blobURL.PutBlob(context.Background(), bytes.NewReader(compressed.Bytes()),
+		azblob.BlobHTTPHeaders{ContentEncoding: "gzip"}, nil, azblob.BlobAccessConditions{})
+	c.Assert(err, chk.IsNil)
+
+	stream := azblob.NewDownloadStream(context.Background(), blobURL.GetBlob, azblob.DownloadStreamOptions{DecompressResponse: true})
+	defer stream.Close()
+	data, err := ioutil.ReadAll(stream)
+	c.Assert(err, chk.IsNil)
+	c.Assert(string(data), chk.Equals, blockBlobDefaultData)
+}
+
+func (s *aztestsSuite) TestDownloadStreamDecompressEncodings(c *chk.C) {
+	compress := func(newWriter func(w io.Writer) io.WriteCloser) string {
+		b := &bytes.Buffer{}
+		w := newWriter(b)
+		w.Write([]byte(blockBlobDefaultData))
+		w.Close()
+		return b.String()
+	}
+	testCases := []struct {
+		encoding string
+		body     string
+	}{
+		{"gzip", compress(func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) })},
+		{"deflate", compress(func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) })},
+		{"deflate", compress(func(w io.Writer) io.WriteCloser { fw, _ := flate.NewWriter(w, flate.DefaultCompression); return fw })}, // Raw deflate
+		{"", blockBlobDefaultData},
+	}
+	u, _ := url.Parse("http://account.blob.core.windows.net/container/blob")
+	for _, tc := range testCases {
+		service := respondWith(http.StatusOK, http.Header{"Content-Encoding": []string{tc.encoding}}, tc.body)
+		blobURL := azblob.NewBlobURL(*u, pipeline.NewPipeline([]pipeline.Factory{pipeline.MethodFactoryMarker(), service}, pipeline.Options{}))
+		getBlob := func(ctx context.Context, blobRange azblob.BlobRange, ac azblob.BlobAccessConditions, rangeGetContentMD5 bool) (*azblob.GetResponse, error) {
+			return blobURL.GetBlob(ctx, blobRange, ac, rangeGetContentMD5)
+		}
+
+		stream := azblob.NewDownloadStream(context.Background(), getBlob, azblob.DownloadStreamOptions{DecompressResponse: true})
+		data, err := ioutil.ReadAll(stream)
+		stream.Close()
+		c.Assert(err, chk.IsNil)
+		c.Assert(string(data), chk.Equals, blockBlobDefaultData)
+	}
+}
+
+func (s *aztestsSuite) TestDownloadStreamDecompressResponseRange(c *chk.C) {
+	bsu := getBSU()
+	containerURL, _ := createNewContainer(c, bsu)
+	defer deleteContainer(c, containerURL)
+	blobURL, _ := getBlockBlobURL(c, containerURL)
+
+	compressed := &bytes.Buffer{}
+	gz := gzip.NewWriter(compressed)
+	gz.Write([]byte(blockBlobDefaultData))
+	gz.Close()
+	_, err := blobURL.PutBlob(context.Background(), bytes.NewReader(compressed.Bytes()),
+		azblob.BlobHTTPHeaders{ContentEncoding: "gzip"}, nil, azblob.BlobAccessConditions{})
+	c.Assert(err, chk.IsNil)
+
+	stream := azblob.NewDownloadStream(context.Background(), blobURL.GetBlob,
+		azblob.DownloadStreamOptions{Range: azblob.BlobRange{Offset: 5}, DecompressResponse: true})
+	defer stream.Close()
+	_, err = ioutil.ReadAll(stream)
+	c.Assert(err, chk.Equals, azblob.ErrDecompressRange)
+
+	// A range of an uncompressed blob is fine
+	_, err = blobURL.PutBlob(context.Background(), strings.NewReader(blockBlobDefaultData),
+		azblob.BlobHTTPHeaders{}, nil, azblob.BlobAccessConditions{})
+	c.Assert(err, chk.IsNil)
+	stream = azblob.NewDownloadStream(context.Background(), blobURL.GetBlob,
+		azblob.DownloadStreamOptions{Range: azblob.BlobRange{Offset: 5}, DecompressResponse: true})
+	defer stream.Close()
+	data, err := ioutil.ReadAll(stream)
+	c.Assert(err, chk.IsNil)
+	c.Assert(string(data), chk.Equals, blockBlobDefaultData[5:])
+}
+
+func (s *aztestsSuite) TestDownloadStreamDecompressRangeErrorPersists(c *chk.C) {
+	u, _ := url.Parse("http://account.blob.core.windows.net/container/blob")
+	service := respondWith(http.StatusPartialContent,
+		http.Header{"Content-Encoding": []string{"gzip"}, "Content-Range": []string{"bytes 5-14/100"}}, "compressed")
+	blobURL := azblob.NewBlobURL(*u, pipeline.NewPipeline([]pipeline.Factory{pipeline.MethodFactoryMarker(), service}, pipeline.Options{}))
+
+	stream := azblob.NewDownloadStream(context.Background(), blobURL.GetBlob,
+		azblob.DownloadStreamOptions{Range: azblob.BlobRange{Offset: 5}, DecompressResponse: true})
+	defer stream.Close()
+	p := make([]byte, 10)
+	n, err := stream.Read(p)
+	c.Assert(n, chk.Equals, 0)
+	c.Assert(err, chk.Equals, azblob.ErrDecompressRange)
+
+	// Later reads must not hand back the raw compressed bytes
+	n, err = stream.Read(p)
+	c.Assert(n, chk.Equals, 0)
+	c.Assert(err, chk.Equals, azblob.ErrDecompressRange)
+}
+
+func (s *aztestsSuite) TestDownloadStreamNotModifiedIsError(c *chk.C) {
+	u, _ := url.Parse("http://account.blob.core.windows.net/container/blob")
+	service := respondWith(http.StatusNotModified, http.Header{"Etag": []string{`"x"`}}, "")
+	blobURL := azblob.NewBlobURL(*u, pipeline.NewPipeline([]pipeline.Factory{pipeline.MethodFactoryMarker(), service}, pipeline.Options{}))
+
+	stream := azblob.NewDownloadStream(context.Background(), blobURL.GetBlob,
+		azblob.DownloadStreamOptions{AccessConditions: azblob.BlobAccessConditions{
+			HTTPAccessConditions: azblob.HTTPAccessConditions{IfNoneMatch: azblob.ETag(`"x"`)}}})
+	defer stream.Close()
+	_, err := ioutil.ReadAll(stream)
+	c.Assert(err, chk.NotNil)
+	serr, ok := err.(azblob.StorageError)
+	c.Assert(ok, chk.Equals, true)
+	c.Assert(serr.Response().StatusCode, chk.Equals, http.StatusNotModified)
+
+	seekable := azblob.NewSeekableDownloadStream(context.Background(), blobURL.GetBlob, 10,
+		azblob.SeekableDownloadStreamOptions{AccessConditions: azblob.BlobAccessConditions{
+			HTTPAccessConditions: azblob.HTTPAccessConditions{IfNoneMatch: azblob.ETag(`"x"`)}}})
+	defer seekable.Close()
+	_, err = ioutil.ReadAll(seekable)
+	c.Assert(err, chk.NotNil)
+}
+
+func (s *aztestsSuite) TestPutBlocksFromReader(c *chk.C) {
+	bsu := getBSU()
+	containerURL, _ := createNewContainer(c, bsu)
+	defer deleteContainer(c, containerURL)
+	blobURL, _ := getBlockBlobURL(c, containerURL)
+
+	_, data := getRandomDataAndReader(10*1024 + 100)
+	blockIDs, err := azblob.PutBlocksFromReader(context.Background(), blobURL, bytes.NewBuffer(data), 1024, 3, azblob.PutBlocksFromReaderOptions{})
+	c.Assert(err, chk.IsNil)
+	c.Assert(blockIDs, chk.HasLen, 11)
+
+	_, err = blobURL.PutBlockList(context.Background(), blockIDs, nil, azblob.BlobHTTPHeaders{}, azblob.BlobAccessConditions{})
+	c.Assert(err, chk.IsNil)
+	resp, err := blobURL.GetBlob(context.Background(), azblob.BlobRange{}, azblob.BlobAccessConditions{}, false)
+	c.Assert(err, chk.IsNil)
+	downloaded, err := ioutil.ReadAll(resp.Body())
+	c.Assert(err, chk.IsNil)
+	c.Assert(downloaded, chk.DeepEquals, data) // Blocks were committed in the order they were read
+}
+
+// countingReader counts the bytes read from it.
+type countingReader struct {
+	r         io.Reader
+	bytesRead int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	atomic.AddInt64(&r.bytesRead, int64(n))
+	return n, err
+}
+
+func (s *aztestsSuite) TestPutBlocksFromReaderBoundsMemory(c *chk.C) {
+	const blockSize, parallelism = 1024, 2
+	source := &countingReader{r: bytes.NewReader(make([]byte, 100*blockSize))}
+	// The sender slowly accepts PutBlock requests and records the peak number of bytes read from the source but not yet sent
+	var bytesSent, peakBuffered int64
+	sender := fakePolicy(func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+		buffered := atomic.LoadInt64(&source.bytesRead) - atomic.LoadInt64(&bytesSent)
+		for peak := atomic.LoadInt64(&peakBuffered); buffered > peak; peak = atomic.LoadInt64(&peakBuffered) {
+			if atomic.CompareAndSwapInt64(&peakBuffered, peak, buffered) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond) // The network is slower than the source
+		n, _ := io.Copy(ioutil.Discard, request.Body)
+		atomic.AddInt64(&bytesSent, n)
+		return newFakeResponse(request, http.StatusCreated, nil, ""), nil
+	})
+	p := pipeline.NewPipeline([]pipeline.Factory{pipeline.MethodFactoryMarker(), sender}, pipeline.Options{})
+	u, _ := url.Parse("http://account.blob.core.windows.net/container/blob")
+	blobURL := azblob.NewBlockBlobURL(*u, p)
+
+	blockIDs, err := azblob.PutBlocksFromReader(context.Background(), blobURL, source, blockSize, parallelism, azblob.PutBlocksFromReaderOptions{})
+	c.Assert(err, chk.IsNil)
+	c.Assert(blockIDs, chk.HasLen, 100)
+	c.Assert(peakBuffered <= parallelism*blockSize, chk.Equals, true) // Reading waited for the sender
+}
+
+func (s *aztestsSuite) TestPutBlocksFromReaderMaxBlockCount(c *chk.C) {
+	tries := 0
+	service := fakePolicy(func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+		tries++ // Parallelism is 1 so PutBlocks aren't concurrent
+		return newFakeResponse(request, http.StatusCreated, nil, ""), nil
+	})
+	p := pipeline.NewPipeline([]pipeline.Factory{pipeline.MethodFactoryMarker(), service}, pipeline.Options{})
+	u, _ := url.Parse("http://account.blob.core.windows.net/container/blob")
+	blobURL := azblob.NewBlockBlobURL(*u, p)
+
+	blockIDs, err := azblob.PutBlocksFromReader(context.Background(), blobURL, bytes.NewReader(make([]byte, 3*1024)), 1024, 1,
+		azblob.PutBlocksFromReaderOptions{MaxBlockCount: 3})
+	c.Assert(err, chk.IsNil)
+	c.Assert(blockIDs, chk.HasLen, 3)
+
+	tries = 0
+	_, err = azblob.PutBlocksFromReader(context.Background(), blobURL, bytes.NewReader(make([]byte, 3*1024)), 1024, 1,
+		azblob.PutBlocksFromReaderOptions{MaxBlockCount: 2})
+	c.Assert(err, chk.Equals, azblob.ErrTooManyBlocks)
+	c.Assert(tries, chk.Equals, 2) // The third block wasn't sent
+}
+
+func (s *aztestsSuite) TestSeekableDownloadStream(c *chk.C) {
+	bsu := getBSU()
+	containerURL, _ := createNewContainer(c, bsu)
+	defer deleteContainer(c, containerURL)
+	blobURL, _ := getBlockBlobURL(c, containerURL)
+
+	_, data := getRandomDataAndReader(1024)
+	_, err := blobURL.PutBlob(context.Background(), bytes.NewReader(data), azblob.BlobHTTPHeaders{}, nil, azblob.BlobAccessConditions{})
+	c.Assert(err, chk.IsNil)
+
+	stream := azblob.NewSeekableDownloadStream(context.Background(), blobURL.GetBlob, int64(len(data)), azblob.SeekableDownloadStreamOptions{})
+	defer stream.Close()
+	p := make([]byte, 100)
+	_, err = io.ReadFull(stream, p)
+	c.Assert(err, chk.IsNil)
+	c.Assert(p, chk.DeepEquals, data[:100])
+
+	offset, err := stream.Seek(-200, io.SeekEnd)
+	c.Assert(err, chk.IsNil)
+	c.Assert(offset, chk.Equals, int64(824))
+	rest, err := ioutil.ReadAll(stream)
+	c.Assert(err, chk.IsNil)
+	c.Assert(rest, chk.DeepEquals, data[824:])
+
+	_, err = stream.Seek(10, io.SeekStart) // Seek backward
+	c.Assert(err, chk.IsNil)
+	_, err = io.ReadFull(stream, p)
+	c.Assert(err, chk.IsNil)
+	c.Assert(p, chk.DeepEquals, data[10:110])
+
+	_, err = stream.Seek(-1000, io.SeekCurrent)
+	c.Assert(err, chk.NotNil)
+}
+
+func (s *aztestsSuite) TestSeekableDownloadStreamReadAhead(c *chk.C) {
+	bsu := getBSU()
+	containerURL, _ := createNewContainer(c, bsu)
+	defer deleteContainer(c, containerURL)
+	blobURL, _ := getBlockBlobURL(c, containerURL)
+
+	_, data := getRandomDataAndReader(1024)
+	_, err := blobURL.PutBlob(context.Background(), bytes.NewReader(data), azblob.BlobHTTPHeaders{}, nil, azblob.BlobAccessConditions{})
+	c.Assert(err, chk.IsNil)
+
+	gets := 0
+	getBlob := func(ctx context.Context, blobRange azblob.BlobRange, ac azblob.BlobAccessConditions, rangeGetContentMD5 bool) (*azblob.GetResponse, error) {
+		gets++
+		return blobURL.GetBlob(ctx, blobRange, ac, rangeGetContentMD5)
+	}
+	stream := azblob.NewSeekableDownloadStream(context.Background(), getBlob, int64(len(data)),
+		azblob.SeekableDownloadStreamOptions{ReadAheadSize: 512})
+	defer stream.Close()
+
+	p := make([]byte, 10)
+	for _, offset := range []int64{0, 100, 50, 500, 20} { // All within the first window
+		_, err = stream.Seek(offset, io.SeekStart)
+		c.Assert(err, chk.IsNil)
+		_, err = io.ReadFull(stream, p)
+		c.Assert(err, chk.IsNil)
+		c.Assert(p, chk.DeepEquals, data[offset:offset+10])
+	}
+	c.Assert(gets, chk.Equals, 1)
+
+	_, err = stream.Seek(508, io.SeekStart) // Spans both windows
+	c.Assert(err, chk.IsNil)
+	rest, err := ioutil.ReadAll(stream)
+	c.Assert(err, chk.IsNil)
+	c.Assert(rest, chk.DeepEquals, data[508:])
+	c.Assert(gets, chk.Equals, 2)
+}
+
+func (s *aztestsSuite) TestUploadFileToBlockBlobDetectContentType(c *chk.C) {
+	bsu := getBSU()
+	containerURL, _ := createNewContainer(c, bsu)
+	defer deleteContainer(c, containerURL)
+
+	dir, err := ioutil.TempDir("", "azblob")
+	c.Assert(err, chk.IsNil)
+	defer os.RemoveAll(dir)
+
+	for _, t := range []struct {
+		name, data, headerContentType, expected string
+	}{
+		{"page.html", "<p>hello</p>", "", "text/html; charset=utf-8"},
+		{"data.unknownext", "<html><body></body></html>", "", "text/html; charset=utf-8"}, // Sniffed
+		{"page.html", "<p>hello</p>", "text/plain", "text/plain"},                         // An explicit type wins
+	} {
+		path := filepath.Join(dir, t.name)
+		c.Assert(ioutil.WriteFile(path, []byte(t.data), 0600), chk.IsNil)
+		file, err := os.Open(path)
+		c.Assert(err, chk.IsNil)
+
+		blobURL, _ := getBlockBlobURL(c, containerURL)
+		_, err = azblob.UploadFileToBlockBlob(context.Background(), file, blobURL, azblob.UploadStreamToBlockBlobOptions{
+			BlockSize: 1024, DetectContentType: true, BlobHTTPHeaders: azblob.BlobHTTPHeaders{ContentType: t.headerContentType}})
+		file.Close()
+		c.Assert(err, chk.IsNil)
+
+		props, err := blobURL.GetPropertiesAndMetadata(context.Background(), azblob.BlobAccessConditions{})
+		c.Assert(err, chk.IsNil)
+		c.Assert(props.ContentType(), chk.Equals, t.expected)
+	}
+}
+
+func (s *aztestsSuite) TestUploadStreamToBlockBlobMaxBlockCount(c *chk.C) {
+	u, _ := url.Parse("http://account.blob.core.windows.net/container/blob")
+	blobURL := azblob.NewBlockBlobURL(*u, pipeline.NewPipeline(nil, pipeline.Options{}))
+	stream := bytes.NewReader(make([]byte, 3*1024))
+
+	c.Assert(func() {
+		azblob.UploadStreamToBlockBlob(context.Background(), stream, stream.Size(), blobURL,
+			azblob.UploadStreamToBlockBlobOptions{BlockSize: 1024, MaxBlockCount: 2})
+	}, chk.Panics, "The streamSize is too big or the BlockSize is too small; the number of blocks must be <= 2")
+	c.Assert(func() {
+		azblob.UploadStreamToBlockBlob(context.Background(), stream, stream.Size(), blobURL,
+			azblob.UploadStreamToBlockBlobOptions{BlockSize: 1024, MaxBlockCount: -1})
+	}, chk.Panics, "MaxBlockCount must be >= 0")
+}
+
+func (s *aztestsSuite) TestUploadHTTPHeadersPassthrough(c *chk.C) {
+	bsu := getBSU()
+	containerURL, _ := createNewContainer(c, bsu)
+	defer deleteContainer(c, containerURL)
+
+	_, data := getRandomDataAndReader(3 * 1024)
+	h := azblob.BlobHTTPHeaders{
+		ContentType:        "application/json",
+		ContentEncoding:    "identity",
+		ContentLanguage:    "fr-CA",
+		ContentDisposition: "attachment; filename=data.json",
+		CacheControl:       "max-age=60",
+		ContentMD5:         md5.Sum(data),
+	}
+
+	// Single PutBlob
+	blobURL, _ := getBlockBlobURL(c, containerURL)
+	_, err := blobURL.PutBlob(context.Background(), bytes.NewReader(data), h, nil, azblob.BlobAccessConditions{})
+	c.Assert(err, chk.IsNil)
+	props, err := blobURL.GetPropertiesAndMetadata(context.Background(), azblob.BlobAccessConditions{})
+	c.Assert(err, chk.IsNil)
+	c.Assert(props.NewHTTPHeaders(), chk.DeepEquals, h)
+
+	// PutBlock calls committed by PutBlockList
+	blobURL, _ = getBlockBlobURL(c, containerURL)
+	_, err = azblob.UploadStreamToBlockBlob(context.Background(), bytes.NewReader(data), int64(len(data)), blobURL,
+		azblob.UploadStreamToBlockBlobOptions{BlockSize: 1024, BlobHTTPHeaders: h})
+	c.Assert(err, chk.IsNil)
+	props, err = blobURL.GetPropertiesAndMetadata(context.Background(), azblob.BlobAccessConditions{})
+	c.Assert(err, chk.IsNil)
+	c.Assert(props.NewHTTPHeaders(), chk.DeepEquals, h)
+}
+
+// rangeSender returns a fakePolicy that serves the requested range of data and records each request's range and
+// query; the 1st response's body fails with a temporary network error after failAfter bytes.
+func rangeSender(data string, failAfter int, ranges, queries *[]string) fakePolicy {
+	return func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+		r := request.Header.Get("x-ms-range")
+		*ranges = append(*ranges, r)
+		*queries = append(*queries, request.URL.RawQuery)
+		offset := 0
+		if r != "" {
+			fmt.Sscanf(r, "bytes=%d-", &offset)
+		}
+		response := newFakeResponse(request, http.StatusPartialContent, http.Header{"Etag": []string{`"0x8D5"`}}, data[offset:])
+		if len(*ranges) == 1 {
+			response.Response().Body = ioutil.NopCloser(io.MultiReader(io.LimitReader(response.Response().Body, int64(failAfter)), &failingReader{}))
+		}
+		return response, nil
+	}
+}
+
+// failingReader fails every Read with a temporary network error.
+type failingReader struct{}
+
+func (*failingReader) Read([]byte) (int, error) { return 0, &retryError{temporary: true} }
+
+func (s *aztestsSuite) TestDownloadStreamResumesAfterMidStreamFailure(c *chk.C) {
+	var ranges, queries []string
+	p := pipeline.NewPipeline([]pipeline.Factory{pipeline.MethodFactoryMarker(), rangeSender("0123456789", 4, &ranges, &queries)}, pipeline.Options{})
+	u, _ := url.Parse("https://myaccount.blob.core.windows.net/mycontainer/myblob")
+	blobURL := azblob.NewBlobURL(*u, p)
+
+	stream := azblob.NewDownloadStream(context.Background(), blobURL.GetBlob, azblob.DownloadStreamOptions{})
+	defer stream.Close()
+	data, err := ioutil.ReadAll(stream)
+	c.Assert(err, chk.IsNil)
+	c.Assert(string(data), chk.Equals, "0123456789")
+
+	// The 2nd GET resumes where the failed body left off
+	c.Assert(ranges, chk.HasLen, 2)
+	c.Assert(ranges[1], chk.Matches, "bytes=4-.*")
+}
+
+func (s *aztestsSuite) TestGetBlobWithNewURL(c *chk.C) {
+	var ranges, queries []string
+	p := pipeline.NewPipeline([]pipeline.Factory{pipeline.MethodFactoryMarker(), rangeSender("0123456789", 4, &ranges, &queries)}, pipeline.Options{})
+	sasNumber := 0
+	getNewURL := func() url.URL {
+		sasNumber++ // Simulate signing a new SAS for each GET
+		u, _ := url.Parse(fmt.Sprintf("https://myaccount.blob.core.windows.net/mycontainer/myblob?sig=%d", sasNumber))
+		return *u
+	}
+
+	stream := azblob.NewDownloadStream(context.Background(), azblob.GetBlobWithNewURL(getNewURL, p), azblob.DownloadStreamOptions{})
+	defer stream.Close()
+	data, err := ioutil.ReadAll(stream)
+	c.Assert(err, chk.IsNil)
+	c.Assert(string(data), chk.Equals, "0123456789")
+	c.Assert(queries, chk.DeepEquals, []string{"sig=1", "sig=2"})
+}
+
+func (s *aztestsSuite) TestUploadStreamToBlockBlobIfNotExists(c *chk.C) {
+	bsu := getBSU()
+	containerURL, _ := createNewContainer(c, bsu)
+	defer deleteContainer(c, containerURL)
+	blobURL, _ := getBlockBlobURL(c, containerURL)
+
+	stream, _ := getRandomDataAndReader(3 * 1024)
+	o := azblob.UploadStreamToBlockBlobOptions{BlockSize: 1024,
+		AccessConditions: azblob.BlobAccessConditions{HTTPAccessConditions: azblob.HTTPAccessConditions{IfNoneMatch: azblob.ETagAny}}}
+	_, err := azblob.UploadStreamToBlockBlob(ctx, stream, stream.Size(), blobURL, o)
+	c.Assert(err, chk.IsNil)
+
+	// The 2nd upload is refused before any block is sent
+	_, err = azblob.UploadStreamToBlockBlob(ctx, stream, stream.Size(), blobURL, o)
+	c.Assert(err, chk.Equals, azblob.ErrBlobAlreadyExists)
+	blockList, err := blobURL.GetBlockList(ctx, azblob.BlockListUncommitted, azblob.LeaseAccessConditions{})
+	c.Assert(err, chk.IsNil)
+	c.Assert(blockList.UncommittedBlocks, chk.HasLen, 0)
+}
+
+func (s *aztestsSuite) TestUploadStreamToBlockBlobCreatedDuringUpload(c *chk.C) {
+	u, _ := url.Parse("http://account.blob.core.windows.net/container/blob")
+	// Another client creates the blob while blocks are being sent: the blob doesn't exist when
+	// UploadStreamToBlockBlob checks but the commit is refused.
+	sender := fakePolicy(func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+		switch {
+		case request.Method == http.MethodHead:
+			return newFakeResponse(request, http.StatusNotFound, nil, ""), nil
+		case request.URL.Query().Get("comp") == "blocklist":
+			return newFakeResponse(request, http.StatusConflict, nil, "<?xml version=\"1.0\" encoding=\"utf-8\"?>"+
+				"<Error><Code>BlobAlreadyExists</Code><Message>The specified blob already exists.</Message></Error>"), nil
+		}
+		return newFakeResponse(request, http.StatusCreated, nil, ""), nil
+	})
+	p := pipeline.NewPipeline([]pipeline.Factory{pipeline.MethodFactoryMarker(), sender}, pipeline.Options{})
+	blobURL := azblob.NewBlockBlobURL(*u, p)
+
+	stream, _ := getRandomDataAndReader(2 * 1024)
+	createOnly := false
+	_, err := azblob.UploadStreamToBlockBlob(ctx, stream, stream.Size(), blobURL,
+		azblob.UploadStreamToBlockBlobOptions{BlockSize: 1024, Overwrite: &createOnly})
+	existsErr, ok := err.(*azblob.BlobAlreadyExistsError)
+	c.Assert(ok, chk.Equals, true)
+	c.Assert(existsErr.Is(azblob.ErrBlobAlreadyExists), chk.Equals, true)
+	c.Assert(existsErr.ServiceCode(), chk.Equals, azblob.ServiceCodeBlobAlreadyExists)
+	c.Assert(existsErr.Response().StatusCode, chk.Equals, http.StatusConflict)
+}
+
+func (s *aztestsSuite) TestUploadStreamToBlockBlobOverwrite(c *chk.C) {
+	bsu := getBSU()
+	containerURL, _ := createNewContainer(c, bsu)
+	defer deleteContainer(c, containerURL)
+	blobURL, _ := createNewBlockBlob(c, containerURL)
+
+	stream, _ := getRandomDataAndReader(2 * 1024)
+	overwrite, createOnly := true, false
+	o := azblob.UploadStreamToBlockBlobOptions{BlockSize: 1024, Overwrite: &createOnly}
+	_, err := azblob.UploadStreamToBlockBlob(ctx, stream, stream.Size(), blobURL, o)
+	c.Assert(err, chk.Equals, azblob.ErrBlobAlreadyExists)
+
+	o.Overwrite = &overwrite
+	_, err = azblob.UploadStreamToBlockBlob(ctx, stream, stream.Size(), blobURL, o)
+	c.Assert(err, chk.IsNil)
+	props, err := blobURL.GetProperties(ctx, azblob.BlobAccessConditions{})
+	c.Assert(err, chk.IsNil)
+	c.Assert(props.ContentLength(), chk.Equals, stream.Size())
+
+	// Create-only succeeds when the blob doesn't exist
+	newBlobURL, _ := getBlockBlobURL(c, containerURL)
+	o.Overwrite = &createOnly
+	_, err = azblob.UploadStreamToBlockBlob(ctx, stream, stream.Size(), newBlobURL, o)
+	c.Assert(err, chk.IsNil)
+
+	o.Overwrite = &overwrite
+	o.AccessConditions.IfNoneMatch = azblob.ETagAny
+	c.Assert(func() { azblob.UploadStreamToBlockBlob(ctx, stream, stream.Size(), blobURL, o) }, chk.Panics,
+		"Overwrite can't be true when AccessConditions.IfNoneMatch is ETagAny")
+}
+
+func (s *aztestsSuite) TestUploadDirectoryToContainer(c *chk.C) {
+	bsu := getBSU()
+	containerURL, _ := createNewContainer(c, bsu)
+	defer deleteContainer(c, containerURL)
+
+	dir, err := ioutil.TempDir("", "upload")
+	c.Assert(err, chk.IsNil)
+	defer os.RemoveAll(dir)
+	c.Assert(os.MkdirAll(filepath.Join(dir, "sub", "deeper"), 0700), chk.IsNil)
+	for _, name := range []string{"a.txt", "sub/b.txt", "sub/deeper/c.txt", "sub/skip.log"} {
+		c.Assert(ioutil.WriteFile(filepath.Join(dir, filepath.FromSlash(name)), []byte(name), 0600), chk.IsNil)
+	}
+
+	uploaded, err := azblob.UploadDirectoryToContainer(ctx, containerURL, dir, azblob.UploadDirectoryToContainerOptions{
+		Parallelism: 2, Exclude: "*.log", Upload: azblob.UploadStreamToBlockBlobOptions{BlockSize: 1024}})
+	c.Assert(err, chk.IsNil)
+	c.Assert(uploaded, chk.DeepEquals, []string{"a.txt", "sub/b.txt", "sub/deeper/c.txt"})
+
+	// Each blob is named after its file's relative path and holds the file's contents
+	for _, name := range uploaded {
+		resp, err := containerURL.NewBlobURL(name).GetBlob(ctx, azblob.BlobRange{}, azblob.BlobAccessConditions{}, false)
+		c.Assert(err, chk.IsNil)
+		data, err := ioutil.ReadAll(resp.Body())
+		c.Assert(err, chk.IsNil)
+		c.Assert(string(data), chk.Equals, name)
+	}
+}
+
+func (s *aztestsSuite) TestDownloadContainerToDirectory(c *chk.C) {
+	bsu := getBSU()
+	containerURL, _ := createNewContainer(c, bsu)
+	defer deleteContainer(c, containerURL)
+	for _, name := range []string{"dir/a.txt", "dir/sub/b.txt", "other.txt"} {
+		_, err := containerURL.NewBlockBlobURL(name).PutBlob(ctx, strings.NewReader(name), azblob.BlobHTTPHeaders{}, azblob.Metadata{}, azblob.BlobAccessConditions{})
+		c.Assert(err, chk.IsNil)
+	}
+
+	dir, err := ioutil.TempDir("", "download")
+	c.Assert(err, chk.IsNil)
+	defer os.RemoveAll(dir)
+
+	progress := map[string]int64{}
+	lock := sync.Mutex{}
+	o := azblob.DownloadContainerToDirectoryOptions{SkipUnchanged: true, Progress: func(blobName string, bytesTransferred int64) {
+		lock.Lock()
+		progress[blobName] = bytesTransferred
+		lock.Unlock()
+	}}
+	downloaded, err := azblob.DownloadContainerToDirectory(ctx, containerURL, "dir/", dir, o)
+	c.Assert(err, chk.IsNil)
+	c.Assert(downloaded, chk.DeepEquals, []string{"dir/a.txt", "dir/sub/b.txt"})
+	for _, name := range downloaded {
+		data, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		c.Assert(err, chk.IsNil)
+		c.Assert(string(data), chk.Equals, name)
+		c.Assert(progress[name], chk.Equals, int64(len(name)))
+	}
+
+	// Only the blob that changed since the last download is downloaded again
+	_, err = containerURL.NewBlockBlobURL("dir/a.txt").PutBlob(ctx, strings.NewReader("changed"), azblob.BlobHTTPHeaders{}, azblob.Metadata{}, azblob.BlobAccessConditions{})
+	c.Assert(err, chk.IsNil)
+	downloaded, err = azblob.DownloadContainerToDirectory(ctx, containerURL, "dir/", dir, o)
+	c.Assert(err, chk.IsNil)
+	c.Assert(downloaded, chk.DeepEquals, []string{"dir/a.txt"})
+	data, err := ioutil.ReadFile(filepath.Join(dir, "dir", "a.txt"))
+	c.Assert(err, chk.IsNil)
+	c.Assert(string(data), chk.Equals, "changed")
+}
@@ -0,0 +1,47 @@
+package azblob_test
+
+import (
+	"net/url"
+	"time"
+
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-storage-blob-go/2016-05-31/azblob"
+)
+
+func (s *aztestsSuite) TestBlobURLPartsRoundTripsSpecialCharacters(c *chk.C) {
+	u, _ := url.Parse("https://account.blob.core.windows.net/container")
+	containerURL := azblob.NewContainerURL(*u, azblob.NewPipeline(azblob.NewAnonymousCredential(), azblob.PipelineOptions{}))
+	snapshot := time.Date(2011, 3, 9, 1, 42, 34, 936000000, time.UTC)
+
+	testCases := []struct {
+		blobName   string
+		escapedURL string
+	}{
+		{"a b+c#d/e", "https://account.blob.core.windows.net/container/a%20b+c%23d/e"},
+		{"plus+plus", "https://account.blob.core.windows.net/container/plus+plus"},
+		{"100%", "https://account.blob.core.windows.net/container/100%25"},
+		{"a%2Fb", "https://account.blob.core.windows.net/container/a%252Fb"},
+		{"q?x=1", "https://account.blob.core.windows.net/container/q%3Fx=1"},
+		{"日本語/ü", "https://account.blob.core.windows.net/container/%E6%97%A5%E6%9C%AC%E8%AA%9E/%C3%BC"},
+	}
+	for _, tc := range testCases {
+		blobURL := containerURL.NewBlobURL(tc.blobName)
+		original := blobURL.URL()
+		c.Assert(original.String(), chk.Equals, tc.escapedURL, chk.Commentf("blob name %q", tc.blobName))
+
+		parts := azblob.NewBlobURLParts(original)
+		c.Assert(parts.ContainerName, chk.Equals, "container")
+		c.Assert(parts.BlobName, chk.Equals, tc.blobName)
+		roundTripped := parts.URL()
+		c.Assert(roundTripped.String(), chk.Equals, tc.escapedURL, chk.Commentf("blob name %q", tc.blobName))
+
+		// The snapshot survives the round-trip alongside other query parameters
+		parts.Snapshot = snapshot
+		parts.UnparsedParams = "comp=metadata"
+		parts = azblob.NewBlobURLParts(parts.URL())
+		c.Assert(parts.BlobName, chk.Equals, tc.blobName)
+		c.Assert(parts.Snapshot.Equal(snapshot), chk.Equals, true)
+		c.Assert(parts.UnparsedParams, chk.Equals, "comp=metadata")
+	}
+}
@@ -0,0 +1,121 @@
+package azblob_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
+
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/2016-05-31/azblob"
+)
+
+func (s *aztestsSuite) TestCircuitBreakerTripsAndRecovers(c *chk.C) {
+	u, _ := url.Parse("http://PrimaryDC")
+	breaker := azblob.NewCircuitBreakerPolicyFactory(azblob.CircuitBreakerOptions{FailureThreshold: 2, Cooldown: 100 * time.Millisecond})
+	statusCode, tries := http.StatusServiceUnavailable, 0
+	service := fakePolicy(func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+		tries++
+		return newFakeResponse(request, statusCode, nil, ""), nil
+	})
+	factories := [...]pipeline.Factory{breaker, service}
+	p := pipeline.NewPipeline(factories[:], pipeline.Options{})
+
+	send := func() error {
+		request, _ := pipeline.NewRequest(http.MethodGet, *u, nil)
+		_, err := p.Do(context.Background(), nil, request)
+		return err
+	}
+
+	// Consecutive failures trip the breaker open
+	c.Assert(send(), chk.IsNil)
+	c.Assert(send(), chk.IsNil)
+	c.Assert(breaker.State(), chk.Equals, azblob.CircuitBreakerOpen)
+
+	// While open, requests fail fast without reaching the service
+	c.Assert(send(), chk.Equals, azblob.ErrCircuitOpen)
+	c.Assert(tries, chk.Equals, 2)
+
+	// After the cooldown, a successful probe closes the breaker
+	time.Sleep(150 * time.Millisecond)
+	c.Assert(breaker.State(), chk.Equals, azblob.CircuitBreakerHalfOpen)
+	statusCode = http.StatusOK
+	c.Assert(send(), chk.IsNil)
+	c.Assert(breaker.State(), chk.Equals, azblob.CircuitBreakerClosed)
+
+	m := breaker.Metrics()
+	c.Assert(m.Requests, chk.Equals, int64(3))
+	c.Assert(m.Failures, chk.Equals, int64(2))
+	c.Assert(m.Rejected, chk.Equals, int64(1))
+	c.Assert(m.Trips, chk.Equals, int64(1))
+}
+
+func (s *aztestsSuite) TestCircuitBreakerIgnoresRequestsSentBeforeOpening(c *chk.C) {
+	u, _ := url.Parse("http://PrimaryDC")
+	breaker := azblob.NewCircuitBreakerPolicyFactory(azblob.CircuitBreakerOptions{FailureThreshold: 1, Cooldown: 100 * time.Millisecond})
+	// A request whose x-ms-test-gate header names a gate is held until the gate is closed; its name is sent on
+	// entered once it is being held
+	gates := map[string]chan struct{}{"slow": make(chan struct{}), "probe": make(chan struct{})}
+	entered := make(chan string, 2)
+	statusCode := int32(http.StatusOK)
+	service := fakePolicy(func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+		if gate, ok := gates[request.Header.Get("x-ms-test-gate")]; ok {
+			entered <- request.Header.Get("x-ms-test-gate")
+			<-gate
+		}
+		return newFakeResponse(request, int(atomic.LoadInt32(&statusCode)), nil, ""), nil
+	})
+	p := pipeline.NewPipeline([]pipeline.Factory{breaker, service}, pipeline.Options{})
+
+	send := func(gate string) error {
+		request, _ := pipeline.NewRequest(http.MethodGet, *u, nil)
+		request.Header.Set("x-ms-test-gate", gate)
+		_, err := p.Do(context.Background(), nil, request)
+		return err
+	}
+	done := make(chan string, 2)
+	go func() { send("slow"); done <- "slow" }() // Sent while the breaker is closed
+	<-entered
+
+	// A failure trips the breaker open and, after the cooldown, the probe is sent
+	atomic.StoreInt32(&statusCode, http.StatusServiceUnavailable)
+	c.Assert(send(""), chk.IsNil)
+	c.Assert(breaker.State(), chk.Equals, azblob.CircuitBreakerOpen)
+	time.Sleep(150 * time.Millisecond)
+	atomic.StoreInt32(&statusCode, http.StatusOK)
+	go func() { send("probe"); done <- "probe" }()
+	<-entered
+
+	// The slow request succeeding says nothing about the probe; the breaker stays half-open
+	close(gates["slow"])
+	c.Assert(<-done, chk.Equals, "slow")
+	c.Assert(breaker.State(), chk.Equals, azblob.CircuitBreakerHalfOpen)
+	c.Assert(send(""), chk.Equals, azblob.ErrCircuitOpen) // The probe is still outstanding
+
+	close(gates["probe"])
+	c.Assert(<-done, chk.Equals, "probe")
+	c.Assert(breaker.State(), chk.Equals, azblob.CircuitBreakerClosed)
+}
+
+func (s *aztestsSuite) TestCircuitBreakerPipelineOption(c *chk.C) {
+	u, _ := url.Parse("http://account.blob.core.windows.net/container/blob")
+	breaker := azblob.NewCircuitBreakerPolicyFactory(azblob.CircuitBreakerOptions{FailureThreshold: 2})
+	tries := 0
+	service := fakePolicy(func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+		tries++
+		return newFakeResponse(request, http.StatusServiceUnavailable, nil, ""), nil
+	})
+	p := azblob.NewPipeline(azblob.NewAnonymousCredential(), azblob.PipelineOptions{Retry: azblob.RetryOptions{MaxTries: 1},
+		CircuitBreaker: breaker, HTTPSender: service})
+	blobURL := azblob.NewBlobURL(*u, p)
+
+	blobURL.GetPropertiesAndMetadata(context.Background(), azblob.BlobAccessConditions{})
+	blobURL.GetPropertiesAndMetadata(context.Background(), azblob.BlobAccessConditions{})
+	c.Assert(breaker.State(), chk.Equals, azblob.CircuitBreakerOpen)
+	_, err := blobURL.GetPropertiesAndMetadata(context.Background(), azblob.BlobAccessConditions{})
+	c.Assert(err, chk.Equals, azblob.ErrCircuitOpen)
+	c.Assert(tries, chk.Equals, 2)
+}
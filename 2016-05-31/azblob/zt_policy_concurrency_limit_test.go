@@ -0,0 +1,85 @@
+package azblob_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/2016-05-31/azblob"
+)
+
+func (s *aztestsSuite) TestConcurrencyLimiter(c *chk.C) {
+	u, _ := url.Parse("http://PrimaryDC")
+	limiter := azblob.NewConcurrencyLimiter(1)
+	started, release := make(chan struct{}, 2), make(chan struct{})
+	blocker := fakePolicy(func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+		started <- struct{}{}
+		<-release
+		return newFakeResponse(request, http.StatusOK, nil, ""), nil
+	})
+	factories := [...]pipeline.Factory{limiter, blocker}
+	p := pipeline.NewPipeline(factories[:], pipeline.Options{})
+
+	done := make(chan error)
+	go func() {
+		request, _ := pipeline.NewRequest(http.MethodGet, *u, nil)
+		_, err := p.Do(context.Background(), nil, request)
+		done <- err
+	}()
+	<-started
+	c.Assert(limiter.InFlight(), chk.Equals, 1)
+
+	// The 2nd request can't get a slot before its context expires
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	request, _ := pipeline.NewRequest(http.MethodGet, *u, nil)
+	_, err := p.Do(ctx, nil, request)
+	c.Assert(err, chk.Equals, context.DeadlineExceeded)
+
+	close(release)
+	c.Assert(<-done, chk.IsNil)
+	c.Assert(limiter.InFlight(), chk.Equals, 0)
+}
+
+func (s *aztestsSuite) TestConcurrencyLimiterWaitDoesntCountAgainstTryTimeout(c *chk.C) {
+	// The service holds the 1st request until release is closed and records when each try arrived and its deadline
+	started, release := make(chan struct{}, 2), make(chan struct{})
+	lock := sync.Mutex{}
+	arrived, deadlines := []time.Time{}, []time.Time{}
+	service := fakePolicy(func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+		deadline, _ := ctx.Deadline()
+		lock.Lock()
+		arrived, deadlines = append(arrived, time.Now()), append(deadlines, deadline)
+		lock.Unlock()
+		started <- struct{}{}
+		<-release
+		return newFakeResponse(request, http.StatusOK, nil, ""), nil
+	})
+	tryTimeout := time.Minute
+	p := azblob.NewPipeline(azblob.NewAnonymousCredential(), azblob.PipelineOptions{
+		MaxConcurrentRequests: 1, Retry: azblob.RetryOptions{TryTimeout: tryTimeout}, HTTPSender: service})
+	u, _ := url.Parse("http://account.blob.core.windows.net/container/blob")
+	blobURL := azblob.NewBlobURL(*u, p)
+
+	done := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := blobURL.GetPropertiesAndMetadata(context.Background(), azblob.BlobAccessConditions{})
+			done <- err
+		}()
+	}
+	<-started
+	time.Sleep(200 * time.Millisecond) // The 2nd request waits for the 1st one's slot
+	close(release)
+	<-started
+	c.Assert(<-done, chk.IsNil)
+	c.Assert(<-done, chk.IsNil)
+
+	// The 2nd try's timeout started once it got a slot, not when its request was made
+	c.Assert(deadlines[1].Sub(arrived[1]) > tryTimeout-100*time.Millisecond, chk.Equals, true)
+}
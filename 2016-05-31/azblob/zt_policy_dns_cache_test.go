@@ -0,0 +1,144 @@
+package azblob_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-storage-blob-go/2016-05-31/azblob"
+)
+
+// fakeResolver resolves every host to addrs and counts the lookups.
+type fakeResolver struct {
+	lock    sync.Mutex
+	addrs   []string
+	lookups int
+	looked  chan struct{} // If not nil, receives a value after each lookup
+}
+
+func (r *fakeResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	r.lock.Lock()
+	r.lookups++
+	addrs := r.addrs
+	r.lock.Unlock()
+	if r.looked != nil {
+		r.looked <- struct{}{}
+	}
+	return addrs, nil
+}
+
+func (r *fakeResolver) count() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.lookups
+}
+
+// fakeDialer records the addresses dialed and fails to connect to those in down.
+type fakeDialer struct {
+	dialed []string
+	down   map[string]bool
+}
+
+func (d *fakeDialer) dial(ctx context.Context, network, address string) (net.Conn, error) {
+	d.dialed = append(d.dialed, address)
+	if d.down[address] {
+		return nil, errors.New("connection refused")
+	}
+	client, server := net.Pipe()
+	server.Close()
+	return client, nil
+}
+
+func (s *aztestsSuite) TestDNSCacheHitAndExpiry(c *chk.C) {
+	now := time.Now()
+	restore := azblob.SetClockForTesting(func() time.Time { return now }, nil)
+	defer restore()
+
+	resolver := &fakeResolver{addrs: []string{"10.0.0.1"}}
+	dialer := &fakeDialer{}
+	dial := azblob.NewDNSCacheDialerForTesting(azblob.DNSCacheOptions{TTL: time.Minute, Resolver: resolver}, dialer.dial)
+
+	for i := 0; i < 3; i++ {
+		conn, err := dial(context.Background(), "tcp", "account.blob.core.windows.net:443")
+		c.Assert(err, chk.IsNil)
+		conn.Close()
+	}
+	c.Assert(resolver.count(), chk.Equals, 1) // The later dials used the cached addresses
+	c.Assert(dialer.dialed, chk.DeepEquals, []string{"10.0.0.1:443", "10.0.0.1:443", "10.0.0.1:443"})
+
+	// Once the TTL has elapsed, the host is resolved again before dialing
+	resolver.addrs = []string{"10.0.0.2"}
+	now = now.Add(2 * time.Minute)
+	conn, err := dial(context.Background(), "tcp", "account.blob.core.windows.net:443")
+	c.Assert(err, chk.IsNil)
+	conn.Close()
+	c.Assert(resolver.count(), chk.Equals, 2)
+	c.Assert(dialer.dialed[3], chk.Equals, "10.0.0.2:443")
+}
+
+func (s *aztestsSuite) TestDNSCacheRefreshesInBackground(c *chk.C) {
+	now := time.Now()
+	restore := azblob.SetClockForTesting(func() time.Time { return now }, nil)
+	defer restore()
+
+	resolver := &fakeResolver{addrs: []string{"10.0.0.1"}, looked: make(chan struct{}, 2)}
+	dialer := &fakeDialer{}
+	dial := azblob.NewDNSCacheDialerForTesting(azblob.DNSCacheOptions{TTL: time.Minute, Resolver: resolver}, dialer.dial)
+
+	conn, err := dial(context.Background(), "tcp", "account.blob.core.windows.net:443")
+	c.Assert(err, chk.IsNil)
+	conn.Close()
+	<-resolver.looked
+
+	// Past half the TTL, the cached addresses are used while a refresh runs in the background
+	resolver.lock.Lock()
+	resolver.addrs = []string{"10.0.0.2"}
+	resolver.lock.Unlock()
+	now = now.Add(40 * time.Second)
+	conn, err = dial(context.Background(), "tcp", "account.blob.core.windows.net:443")
+	c.Assert(err, chk.IsNil)
+	conn.Close()
+	c.Assert(dialer.dialed, chk.DeepEquals, []string{"10.0.0.1:443", "10.0.0.1:443"})
+	select {
+	case <-resolver.looked:
+	case <-time.After(5 * time.Second):
+		c.Fatal("the cache didn't refresh the host's addresses")
+	}
+
+	// Later dials use the refreshed addresses (once the refresh has stored them) without another lookup
+	for deadline := time.Now().Add(5 * time.Second); dialer.dialed[len(dialer.dialed)-1] != "10.0.0.2:443"; {
+		c.Assert(time.Now().Before(deadline), chk.Equals, true)
+		time.Sleep(time.Millisecond)
+		conn, err = dial(context.Background(), "tcp", "account.blob.core.windows.net:443")
+		c.Assert(err, chk.IsNil)
+		conn.Close()
+	}
+	c.Assert(resolver.count(), chk.Equals, 2)
+}
+
+func (s *aztestsSuite) TestDNSCacheRotatesAddresses(c *chk.C) {
+	resolver := &fakeResolver{addrs: []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}}
+	dialer := &fakeDialer{down: map[string]bool{"10.0.0.3:443": true}}
+	dial := azblob.NewDNSCacheDialerForTesting(azblob.DNSCacheOptions{Resolver: resolver}, dialer.dial)
+
+	for i := 0; i < 3; i++ {
+		conn, err := dial(context.Background(), "tcp", "account.blob.core.windows.net:443")
+		c.Assert(err, chk.IsNil)
+		conn.Close()
+	}
+	// Each dial starts with the next address; the unreachable one is skipped in favor of the address after it
+	c.Assert(dialer.dialed, chk.DeepEquals, []string{"10.0.0.2:443", "10.0.0.3:443", "10.0.0.1:443", "10.0.0.1:443"})
+	c.Assert(resolver.count(), chk.Equals, 1)
+
+	// An IP address isn't resolved
+	dialer.dialed = nil
+	conn, err := dial(context.Background(), "tcp", "192.168.0.1:443")
+	c.Assert(err, chk.IsNil)
+	conn.Close()
+	c.Assert(dialer.dialed, chk.DeepEquals, []string{"192.168.0.1:443"})
+	c.Assert(resolver.count(), chk.Equals, 1)
+}
@@ -0,0 +1,42 @@
+package azblob_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/url"
+
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/2016-05-31/azblob"
+)
+
+// recordHeader returns a fakePolicy that stores each request's headers in header and returns 200 (OK).
+func recordHeader(header *http.Header) fakePolicy {
+	return func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+		*header = request.Header
+		return newFakeResponse(request, http.StatusOK, nil, ""), nil
+	}
+}
+
+func (s *aztestsSuite) TestExpectContinuePolicy(c *chk.C) {
+	u, _ := url.Parse("http://PrimaryDC")
+	var header http.Header
+	factories := [...]pipeline.Factory{
+		azblob.NewExpectContinuePolicyFactory(azblob.ExpectContinueOptions{Enabled: true, MinimumBodyBytes: 4}),
+		recordHeader(&header),
+	}
+	p := pipeline.NewPipeline(factories[:], pipeline.Options{})
+
+	send := func(method string, body []byte) string {
+		request, err := pipeline.NewRequest(method, *u, bytes.NewReader(body))
+		c.Assert(err, chk.IsNil)
+		_, err = p.Do(context.Background(), nil, request)
+		c.Assert(err, chk.IsNil)
+		return header.Get("Expect")
+	}
+	c.Assert(send(http.MethodPut, []byte("LargeBody")), chk.Equals, "100-continue")
+	c.Assert(send(http.MethodPut, []byte("Sm")), chk.Equals, "") // Body is below MinimumBodyBytes
+	c.Assert(send(http.MethodGet, nil), chk.Equals, "")
+}
@@ -0,0 +1,70 @@
+package azblob_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/2016-05-31/azblob"
+)
+
+func (s *aztestsSuite) TestFailoverReadsFromSecondaryDuringCooldown(c *chk.C) {
+	now := time.Now()
+	restore := azblob.SetClockForTesting(func() time.Time { return now }, func(d time.Duration) <-chan time.Time {
+		ch := make(chan time.Time, 1)
+		ch <- now
+		return ch
+	})
+	defer restore()
+
+	failover := azblob.NewFailoverPolicyFactory(azblob.FailoverOptions{SecondaryHost: "SecondaryDC", Cooldown: time.Minute})
+	// The service times out tries sent to the primary host while primaryDown and records each try's host
+	primaryDown, hosts := true, []string(nil)
+	service := fakePolicy(func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+		hosts = append(hosts, request.URL.Host)
+		if primaryDown && request.URL.Host == "PrimaryDC" {
+			return nil, context.DeadlineExceeded
+		}
+		return newFakeResponse(request, http.StatusOK, nil, ""), nil
+	})
+	factories := [...]pipeline.Factory{azblob.NewRetryPolicyFactory(azblob.RetryOptions{MaxTries: 3}), failover, service}
+	p := pipeline.NewPipeline(factories[:], pipeline.Options{})
+	u, _ := url.Parse("http://PrimaryDC")
+	send := func(method string) (pipeline.Response, error) {
+		request, _ := pipeline.NewRequest(method, *u, nil)
+		return p.Do(context.Background(), nil, request)
+	}
+
+	// A read that times out against the primary is retried against the secondary
+	response, err := send(http.MethodGet)
+	c.Assert(err, chk.IsNil)
+	c.Assert(failover.ServedBySecondary(response.Response()), chk.Equals, true)
+	c.Assert(hosts, chk.DeepEquals, []string{"PrimaryDC", "SecondaryDC"})
+	c.Assert(failover.Metrics().PrimaryDown, chk.Equals, true)
+
+	// During the cooldown, reads go straight to the secondary but writes still go to the primary
+	primaryDown, hosts = false, nil
+	_, err = send(http.MethodHead)
+	c.Assert(err, chk.IsNil)
+	response, err = send(http.MethodPut)
+	c.Assert(err, chk.IsNil)
+	c.Assert(failover.ServedBySecondary(response.Response()), chk.Equals, false)
+	c.Assert(hosts, chk.DeepEquals, []string{"SecondaryDC", "PrimaryDC"})
+
+	// After the cooldown, reads go to the primary again
+	now = now.Add(time.Minute)
+	hosts = nil
+	_, err = send(http.MethodGet)
+	c.Assert(err, chk.IsNil)
+	c.Assert(hosts, chk.DeepEquals, []string{"PrimaryDC"})
+
+	m := failover.Metrics()
+	c.Assert(m.PrimaryDown, chk.Equals, false)
+	c.Assert(m.Failovers, chk.Equals, int64(1))
+	c.Assert(m.PrimaryRequests, chk.Equals, int64(3))
+	c.Assert(m.SecondaryRequests, chk.Equals, int64(2))
+}
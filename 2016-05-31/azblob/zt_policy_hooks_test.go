@@ -0,0 +1,34 @@
+package azblob_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/2016-05-31/azblob"
+)
+
+func (s *aztestsSuite) TestHookPolicy(c *chk.C) {
+	u, _ := url.Parse("http://PrimaryDC")
+	statusCodes := []int{}
+	requestHook := func(r *http.Request) { r.Header.Set("x-custom", "value") }
+	responseHook := func(r *http.Response, err error) { statusCodes = append(statusCodes, r.StatusCode) }
+	var header http.Header
+	factories := [...]pipeline.Factory{azblob.NewHookPolicyFactory(requestHook, responseHook), recordHeader(&header)}
+	p := pipeline.NewPipeline(factories[:], pipeline.Options{})
+
+	request, _ := pipeline.NewRequest(http.MethodGet, *u, nil)
+	_, err := p.Do(context.Background(), nil, request)
+	c.Assert(err, chk.IsNil)
+	c.Assert(header.Get("x-custom"), chk.Equals, "value")
+	c.Assert(statusCodes, chk.DeepEquals, []int{http.StatusOK})
+
+	// Nil hooks are allowed
+	factories[0] = azblob.NewHookPolicyFactory(nil, nil)
+	p = pipeline.NewPipeline(factories[:], pipeline.Options{})
+	_, err = p.Do(context.Background(), nil, request)
+	c.Assert(err, chk.IsNil)
+}
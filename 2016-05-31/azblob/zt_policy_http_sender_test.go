@@ -0,0 +1,31 @@
+package azblob_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/2016-05-31/azblob"
+)
+
+func (s *aztestsSuite) TestDualStackHTTPSender(c *chk.C) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusAccepted) }))
+	defer server.Close()
+	u, _ := url.Parse(server.URL)
+
+	for _, sender := range []pipeline.Factory{
+		azblob.NewDualStackHTTPSenderFactory(azblob.DualStackOptions{}),
+		azblob.NewDNSCacheHTTPSenderFactory(azblob.DNSCacheOptions{}),
+	} {
+		p := pipeline.NewPipeline(nil, pipeline.Options{HTTPSender: sender})
+		request, _ := pipeline.NewRequest(http.MethodGet, *u, nil)
+		response, err := p.Do(context.Background(), nil, request)
+		c.Assert(err, chk.IsNil)
+		c.Assert(response.Response().StatusCode, chk.Equals, http.StatusAccepted)
+		response.Response().Body.Close()
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"strings"
@@ -181,6 +182,77 @@ func testRetryTestScenario(c *chk.C, scenario retryTestScenario) {
 	cancel()
 }
 
+func (s *aztestsSuite) TestRetryTryTimeoutHonorsContextDeadline(c *chk.C) {
+	u, _ := url.Parse("http://PrimaryDC")
+	retryOptions := azblob.RetryOptions{
+		Policy:     azblob.RetryPolicyExponential,
+		MaxTries:   1,
+		TryTimeout: time.Minute, // Much longer than the context's deadline
+	}
+	// The service never responds; it blocks until the try's context is done
+	var tryDeadline time.Time
+	var queryTimeout string
+	slow := fakePolicy(func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+		tryDeadline, _ = ctx.Deadline()
+		queryTimeout = request.URL.Query().Get("timeout")
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	factories := [...]pipeline.Factory{
+		azblob.NewRetryPolicyFactory(retryOptions),
+		slow,
+	}
+	p := pipeline.NewPipeline(factories[:], pipeline.Options{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	deadline, _ := ctx.Deadline()
+	request, _ := pipeline.NewRequest(http.MethodGet, *u, strings.NewReader("TestData"))
+
+	start := time.Now()
+	_, err := p.Do(ctx, nil, request)
+	c.Assert(err, chk.NotNil)
+	c.Assert(time.Since(start) < 2*time.Second, chk.Equals, true) // The try ended with the context, not TryTimeout
+	c.Assert(tryDeadline.After(deadline), chk.Equals, false)      // The try's deadline never exceeds the context's
+	c.Assert(queryTimeout, chk.Equals, "1")                       // The server-side timeout is rounded up to 1 second
+}
+
+// failTemporarily returns a fakePolicy that fails every try with a temporary error (like a service returning 503)
+// and counts the tries.
+func failTemporarily(tries *int32) fakePolicy {
+	return func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+		*tries++
+		return nil, &retryError{temporary: true}
+	}
+}
+
+func (s *aztestsSuite) TestRetryStopsWhenDelayExceedsContextDeadline(c *chk.C) {
+	u, _ := url.Parse("http://PrimaryDC")
+	retryOptions := azblob.RetryOptions{
+		Policy:        azblob.RetryPolicyExponential,
+		MaxTries:      4,
+		RetryDelay:    5 * time.Second, // The 1st retry's delay is at least 4 seconds
+		MaxRetryDelay: 10 * time.Second,
+	}
+	tries := int32(0)
+	factories := [...]pipeline.Factory{
+		azblob.NewRetryPolicyFactory(retryOptions),
+		failTemporarily(&tries),
+	}
+	p := pipeline.NewPipeline(factories[:], pipeline.Options{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	request, _ := pipeline.NewRequest(http.MethodGet, *u, strings.NewReader("TestData"))
+
+	start := time.Now()
+	_, err := p.Do(ctx, nil, request)
+	c.Assert(time.Since(start) < time.Second, chk.Equals, true) // Returned without sleeping until the deadline
+	_, ok := err.(*retryError)
+	c.Assert(ok, chk.Equals, true)        // The last try's error is returned, not context.DeadlineExceeded
+	c.Assert(tries, chk.Equals, int32(1)) // No retry was attempted
+}
+
 /*
    	Fail primary; retry should be on secondary URL - maybe do this twice
    	Fail secondary; and never see primary again
@@ -201,3 +273,153 @@ func testRetryTestScenario(c *chk.C, scenario retryTestScenario) {
    	error where Temporary() & Timeout don't exist; no retry
     no error; no retry; return success, nil
 */
+
+func (s *aztestsSuite) TestRetryBackoffSchedule(c *chk.C) {
+	// Record each back-off instead of sleeping so the schedule can be checked without waiting for it
+	delays := []time.Duration{}
+	restore := azblob.SetClockForTesting(nil, func(d time.Duration) <-chan time.Time {
+		delays = append(delays, d)
+		ch := make(chan time.Time, 1)
+		ch <- time.Now()
+		return ch
+	})
+	defer restore()
+
+	retryOptions := azblob.RetryOptions{
+		Policy:        azblob.RetryPolicyExponential,
+		MaxTries:      5,
+		RetryDelay:    time.Second,
+		MaxRetryDelay: 10 * time.Second,
+	}
+	tries := int32(0)
+	p := pipeline.NewPipeline([]pipeline.Factory{azblob.NewRetryPolicyFactory(retryOptions), failTemporarily(&tries)}, pipeline.Options{})
+	u, _ := url.Parse("http://PrimaryDC")
+	request, _ := pipeline.NewRequest(http.MethodGet, *u, nil)
+	_, err := p.Do(context.Background(), nil, request)
+	c.Assert(err, chk.NotNil)
+	c.Assert(tries, chk.Equals, retryOptions.MaxTries)
+
+	// The 1st try doesn't wait; retry n waits (2^n - 1) * RetryDelay with [0.8, 1.3) jitter, capped at MaxRetryDelay
+	c.Assert(delays, chk.HasLen, int(retryOptions.MaxTries-1))
+	for i, d := range delays {
+		base := time.Duration(1<<uint(i+1)-1) * retryOptions.RetryDelay
+		min, max := base*8/10, base*13/10
+		if max > retryOptions.MaxRetryDelay {
+			max = retryOptions.MaxRetryDelay
+		}
+		if min > max {
+			min = max
+		}
+		c.Assert(d >= min && d <= max, chk.Equals, true, chk.Commentf("retry %d waited %v; expected [%v, %v]", i+1, d, min, max))
+	}
+}
+
+func (s *aztestsSuite) TestRetryNotifyRetry(c *chk.C) {
+	slept := []time.Duration{}
+	restore := azblob.SetClockForTesting(nil, func(d time.Duration) <-chan time.Time {
+		slept = append(slept, d)
+		ch := make(chan time.Time, 1)
+		ch <- time.Now()
+		return ch
+	})
+	defer restore()
+
+	tries, delays, sent := []int32{}, []time.Duration{}, int32(0)
+	retryOptions := azblob.RetryOptions{
+		MaxTries: 4,
+		NotifyRetry: func(try int32, delay time.Duration, err error, resp *http.Response) {
+			c.Assert(err, chk.FitsTypeOf, &retryError{})
+			c.Assert(resp, chk.IsNil)
+			tries, delays = append(tries, try), append(delays, delay)
+		},
+	}
+	p := pipeline.NewPipeline([]pipeline.Factory{azblob.NewRetryPolicyFactory(retryOptions), failTemporarily(&sent)}, pipeline.Options{})
+	u, _ := url.Parse("http://PrimaryDC")
+	request, _ := pipeline.NewRequest(http.MethodGet, *u, nil)
+	_, err := p.Do(context.Background(), nil, request)
+	c.Assert(err, chk.NotNil)
+
+	// Every retry is announced, in order, with the delay the policy then waits for
+	c.Assert(tries, chk.DeepEquals, []int32{2, 3, 4})
+	c.Assert(delays, chk.DeepEquals, slept)
+}
+
+// retryMetricsRecorder counts the retries reported for each category.
+type retryMetricsRecorder map[string]int
+
+func (r retryMetricsRecorder) IncRetry(category string) { r[category]++ }
+
+func (s *aztestsSuite) TestRetryMetricsCategories(c *chk.C) {
+	restore := azblob.SetClockForTesting(nil, func(d time.Duration) <-chan time.Time {
+		ch := make(chan time.Time, 1)
+		ch <- time.Now()
+		return ch
+	})
+	defer restore()
+
+	temporary := &retryError{temporary: true}
+	// The service's nth try returns the nth of results
+	results := []struct {
+		statusCode int
+		err        error
+	}{
+		{http.StatusServiceUnavailable, temporary},
+		{http.StatusInternalServerError, temporary},
+		{0, &url.Error{Op: "Get", URL: "http://PrimaryDC", Err: &net.OpError{Op: "dial", Err: temporary}}},
+		{0, &net.OpError{Op: "read", Err: temporary}},
+		{0, &retryError{timeout: true}},
+		{0, &retryError{timeout: true}},
+		{http.StatusOK, nil},
+	}
+	tries := 0
+	sender := fakePolicy(func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+		result := results[tries]
+		tries++
+		if result.statusCode == 0 {
+			return nil, result.err
+		}
+		return newFakeResponse(request, result.statusCode, nil, ""), result.err
+	})
+	metrics := retryMetricsRecorder{}
+	p := pipeline.NewPipeline([]pipeline.Factory{azblob.NewRetryPolicyFactory(azblob.RetryOptions{MaxTries: 7, Metrics: metrics}), sender},
+		pipeline.Options{})
+	u, _ := url.Parse("http://PrimaryDC")
+	request, _ := pipeline.NewRequest(http.MethodGet, *u, nil)
+	_, err := p.Do(context.Background(), nil, request)
+	c.Assert(err, chk.IsNil)
+	c.Assert(tries, chk.Equals, 7)
+
+	// Each retried failure is counted once under its category; the successful try isn't counted
+	c.Assert(metrics, chk.DeepEquals, retryMetricsRecorder{
+		azblob.RetryCategoryThrottled:   1,
+		azblob.RetryCategoryServerError: 1,
+		azblob.RetryCategoryDial:        1,
+		azblob.RetryCategoryRead:        1,
+		azblob.RetryCategoryTimeout:     2,
+	})
+}
+
+func (s *aztestsSuite) TestRetryServerTimeout(c *chk.C) {
+	var query url.Values
+	recorder := fakePolicy(func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+		query = request.URL.Query()
+		return newFakeResponse(request, http.StatusOK, nil, ""), nil
+	})
+	p := pipeline.NewPipeline([]pipeline.Factory{azblob.NewRetryPolicyFactory(azblob.RetryOptions{TryTimeout: time.Minute}), recorder},
+		pipeline.Options{})
+	u, _ := url.Parse("http://PrimaryDC")
+	send := func(ctx context.Context) string {
+		request, _ := pipeline.NewRequest(http.MethodGet, *u, nil)
+		_, err := p.Do(ctx, nil, request)
+		c.Assert(err, chk.IsNil)
+		return query.Get("timeout")
+	}
+
+	c.Assert(send(context.Background()), chk.Equals, "60") // From TryTimeout
+	c.Assert(send(azblob.WithServerTimeout(context.Background(), 5)), chk.Equals, "5")
+	c.Assert(send(azblob.WithServerTimeout(context.Background(), 300)), chk.Equals, "60") // TryTimeout is smaller
+	c.Assert(func() { azblob.WithServerTimeout(context.Background(), azblob.MaxServerTimeoutSeconds) }, chk.Not(chk.Panics), nil)
+	c.Assert(func() { azblob.WithServerTimeout(context.Background(), 0) }, chk.Panics, "seconds must be > 0 and <= MaxServerTimeoutSeconds")
+	c.Assert(func() { azblob.WithServerTimeout(context.Background(), azblob.MaxServerTimeoutSeconds+1) }, chk.Panics,
+		"seconds must be > 0 and <= MaxServerTimeoutSeconds")
+}
@@ -0,0 +1,31 @@
+package azblob_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/2016-05-31/azblob"
+)
+
+func (s *aztestsSuite) TestServiceVersionPolicy(c *chk.C) {
+	u, _ := url.Parse("http://PrimaryDC")
+	var header http.Header
+	factories := [...]pipeline.Factory{azblob.NewServiceVersionPolicyFactory(), recordHeader(&header)}
+	p := pipeline.NewPipeline(factories[:], pipeline.Options{})
+
+	send := func(ctx context.Context) string {
+		request, err := pipeline.NewRequest(http.MethodGet, *u, nil)
+		c.Assert(err, chk.IsNil)
+		request.Header.Set("x-ms-version", azblob.ServiceVersion) // As set by every operation
+		_, err = p.Do(ctx, nil, request)
+		c.Assert(err, chk.IsNil)
+		return header.Get("x-ms-version")
+	}
+	c.Assert(send(context.Background()), chk.Equals, azblob.ServiceVersion)
+	c.Assert(send(azblob.WithServiceVersion(context.Background(), "2017-04-17")), chk.Equals, "2017-04-17")
+	c.Assert(func() { azblob.WithServiceVersion(context.Background(), "") }, chk.Panics, "version can't be empty")
+}
@@ -0,0 +1,32 @@
+package azblob_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/2016-05-31/azblob"
+)
+
+func (s *aztestsSuite) TestTelemetryPolicyValuePlacement(c *chk.C) {
+	u, _ := url.Parse("http://PrimaryDC")
+	userAgent := func(o azblob.TelemetryOptions) string {
+		var header http.Header
+		factories := [...]pipeline.Factory{azblob.NewTelemetryPolicyFactory(o), recordHeader(&header)}
+		request, err := pipeline.NewRequest(http.MethodGet, *u, nil)
+		c.Assert(err, chk.IsNil)
+		_, err = pipeline.NewPipeline(factories[:], pipeline.Options{}).Do(context.Background(), nil, request)
+		c.Assert(err, chk.IsNil)
+		return header.Get("User-Agent")
+	}
+
+	sdk := userAgent(azblob.TelemetryOptions{})
+	c.Assert(strings.HasPrefix(sdk, "azsdk-go-azblob/"+azblob.SDKVersion+" ("), chk.Equals, true)
+	c.Assert(userAgent(azblob.TelemetryOptions{Value: "myapp/1.0"}), chk.Equals, "myapp/1.0 "+sdk)
+	c.Assert(userAgent(azblob.TelemetryOptions{Value: "myapp/1.0", Suffix: true}), chk.Equals, sdk+" myapp/1.0")
+	c.Assert(userAgent(azblob.TelemetryOptions{Suffix: true}), chk.Equals, sdk)
+}
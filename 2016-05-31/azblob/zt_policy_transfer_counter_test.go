@@ -0,0 +1,60 @@
+package azblob_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/2016-05-31/azblob"
+)
+
+func (s *aztestsSuite) TestTransferCounterCountsEveryTry(c *chk.C) {
+	restore := azblob.SetClockForTesting(nil, func(d time.Duration) <-chan time.Time {
+		ch := make(chan time.Time, 1)
+		ch <- time.Now()
+		return ch
+	})
+	defer restore()
+
+	// The service reads each try's request body; the 1st try fails and later tries echo the body back
+	tries := 0
+	echo := fakePolicy(func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+		tries++
+		body, err := ioutil.ReadAll(request.Body)
+		if err != nil {
+			return nil, err
+		}
+		if tries == 1 {
+			return nil, &retryError{temporary: true}
+		}
+		return newFakeResponse(request, http.StatusOK, nil, string(body)), nil
+	})
+	factories := []pipeline.Factory{
+		azblob.NewRetryPolicyFactory(azblob.RetryOptions{MaxTries: 2}),
+		pipeline.MethodFactoryMarker(),
+		azblob.NewTransferCounterPolicyFactory(),
+		echo,
+	}
+	p := pipeline.NewPipeline(factories, pipeline.Options{})
+	u, _ := url.Parse("http://PrimaryDC")
+	request, _ := pipeline.NewRequest(http.MethodPut, *u, strings.NewReader("TestData"))
+
+	counter := &azblob.TransferCounter{}
+	response, err := p.Do(azblob.WithTransferCounter(context.Background(), counter), nil, request)
+	c.Assert(err, chk.IsNil)
+	c.Assert(counter.ResponseBytes(), chk.Equals, int64(0)) // Response bodies are counted as they are read
+	body, err := ioutil.ReadAll(response.Response().Body)
+	c.Assert(err, chk.IsNil)
+	c.Assert(string(body), chk.Equals, "TestData")
+
+	// Both tries sent the body; only the 2nd try got a response body
+	c.Assert(counter.Requests(), chk.Equals, int64(2))
+	c.Assert(counter.RequestBytes(), chk.Equals, int64(2*len("TestData")))
+	c.Assert(counter.ResponseBytes(), chk.Equals, int64(len("TestData")))
+}
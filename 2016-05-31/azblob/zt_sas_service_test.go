@@ -0,0 +1,139 @@
+package azblob_test
+
+import (
+	"context"
+	"encoding/base64"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/2016-05-31/azblob"
+)
+
+func (s *aztestsSuite) TestBlobSASRequiresIdentifierOrPermissions(c *chk.C) {
+	credential := azblob.NewSharedKeyCredential("account", "dGVzdGtleQ==")
+	expiry := time.Now().Add(time.Hour)
+
+	_, err := azblob.BlobSASSignatureValues{ExpiryTime: expiry, ContainerName: "container"}.NewSASQueryParameters(credential)
+	c.Assert(err, chk.Equals, azblob.ErrSASPermissionsRequired)
+
+	for _, v := range []azblob.BlobSASSignatureValues{
+		{ExpiryTime: expiry, ContainerName: "container", Permissions: "r"},
+		{ContainerName: "container", BlobName: "blob", Identifier: "policy"},
+		{ExpiryTime: expiry, ContainerName: "container", BlobName: "blob", Identifier: "policy", Permissions: "r"},
+	} {
+		sas, err := v.NewSASQueryParameters(credential)
+		c.Assert(err, chk.IsNil)
+		c.Assert(sas.Signature, chk.Not(chk.Equals), "")
+	}
+}
+
+func (s *aztestsSuite) TestSASQueryParametersVerify(c *chk.C) {
+	credential := azblob.NewSharedKeyCredential("account", "dGVzdGtleQ==")
+	blobSAS, err := azblob.BlobSASSignatureValues{
+		Protocol:      azblob.SASProtocolHTTPS,
+		StartTime:     time.Now().Add(-time.Hour),
+		ExpiryTime:    time.Now().Add(time.Hour),
+		Permissions:   azblob.BlobSASPermissions{Read: true}.String(),
+		IPRange:       azblob.IPRange{Start: net.ParseIP("10.0.0.1"), End: net.ParseIP("10.0.0.9")},
+		ContainerName: "container",
+		BlobName:      "dir/blob",
+	}.NewSASQueryParameters(credential)
+	c.Assert(err, chk.IsNil)
+	accountSAS := azblob.AccountSASSignatureValues{
+		ExpiryTime:    time.Now().Add(time.Hour),
+		Permissions:   azblob.AccountSASPermissions{Read: true, List: true}.String(),
+		Services:      azblob.AccountSASServices{Blob: true}.String(),
+		ResourceTypes: azblob.AccountSASResourceTypes{Container: true, Object: true}.String(),
+	}.NewSASQueryParameters(credential)
+
+	for _, sas := range []azblob.SASQueryParameters{blobSAS, accountSAS} {
+		// Round trip the SAS through its query string as a gateway would
+		values, err := url.ParseQuery(sas.Encode())
+		c.Assert(err, chk.IsNil)
+		parsed := azblob.NewSASQueryParameters(values, false)
+		c.Assert(parsed.Verify(credential, "container", "dir/blob"), chk.IsNil)
+
+		signature, _ := base64.StdEncoding.DecodeString(parsed.Signature)
+		signature[0] ^= 1 // Flip one bit
+		parsed.Signature = base64.StdEncoding.EncodeToString(signature)
+		c.Assert(parsed.Verify(credential, "container", "dir/blob"), chk.Equals, azblob.ErrSASSignatureMismatch)
+	}
+
+	c.Assert(blobSAS.Verify(credential, "container", "otherblob"), chk.Equals, azblob.ErrSASSignatureMismatch)
+	c.Assert(blobSAS.Verify(azblob.NewSharedKeyCredential("account", "b3RoZXJrZXk="), "container", "dir/blob"),
+		chk.Equals, azblob.ErrSASSignatureMismatch)
+	tampered := blobSAS
+	tampered.Permissions = azblob.BlobSASPermissions{Read: true, Write: true}.String()
+	c.Assert(tampered.Verify(credential, "container", "dir/blob"), chk.Equals, azblob.ErrSASSignatureMismatch)
+}
+
+func (s *aztestsSuite) TestSASStringToSign(c *chk.C) {
+	credential := azblob.NewSharedKeyCredential("account", "dGVzdGtleQ==")
+	blobValues := azblob.BlobSASSignatureValues{
+		ExpiryTime:    time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC),
+		Permissions:   "r",
+		ContainerName: "container",
+		BlobName:      "blob",
+	}
+	c.Assert(blobValues.StringToSign("account"), chk.Equals,
+		"r\n\n2030-01-02T03:04:05Z\n/blob/account/container/blob\n\n\n\n"+azblob.SASVersion+"\n\n\n\n\n")
+	blobSAS, err := blobValues.NewSASQueryParameters(credential)
+	c.Assert(err, chk.IsNil)
+	c.Assert(credential.ComputeHMACSHA256(blobValues.StringToSign("account")), chk.Equals, blobSAS.Signature)
+
+	accountValues := azblob.AccountSASSignatureValues{
+		ExpiryTime:    time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC),
+		Permissions:   "rl",
+		Services:      "b",
+		ResourceTypes: "co",
+	}
+	c.Assert(credential.ComputeHMACSHA256(accountValues.StringToSign("account")), chk.Equals,
+		accountValues.NewSASQueryParameters(credential).Signature)
+}
+
+func (s *aztestsSuite) TestContainerGetSASTokenWithPolicy(c *chk.C) {
+	credential := azblob.NewSharedKeyCredential("account", "dGVzdGtleQ==")
+	u, _ := url.Parse("https://account.blob.core.windows.net/mycontainer")
+	containerURL := azblob.NewContainerURL(*u, azblob.NewPipeline(azblob.NewAnonymousCredential(), azblob.PipelineOptions{}))
+
+	_, err := containerURL.GetSASTokenWithPolicy("", credential)
+	c.Assert(err, chk.NotNil)
+
+	token, err := containerURL.GetSASTokenWithPolicy("policy", credential)
+	c.Assert(err, chk.IsNil)
+	values, err := url.ParseQuery(token)
+	c.Assert(err, chk.IsNil)
+	sas := azblob.NewSASQueryParameters(values, false)
+	c.Assert(sas.Identifier, chk.Equals, "policy")
+	c.Assert(sas.Resource, chk.Equals, "c")
+	c.Assert(sas.Permissions, chk.Equals, "") // Permissions & expiry come from the stored access policy
+	c.Assert(sas.ExpiryTime.IsZero(), chk.Equals, true)
+	c.Assert(sas.Verify(credential, "mycontainer", ""), chk.IsNil)
+}
+
+func (s *aztestsSuite) TestNewSASCopySourceURL(c *chk.C) {
+	credential := azblob.NewSharedKeyCredential("account", "dGVzdGtleQ==")
+	u, _ := url.Parse("https://account.blob.core.windows.net/container/dir/blob")
+	source := azblob.NewSASCopySourceURL(*u, credential, time.Hour)
+
+	parts := azblob.NewBlobURLParts(source)
+	c.Assert(parts.SAS.Permissions, chk.Equals, "r")
+	c.Assert(parts.SAS.ExpiryTime.After(time.Now()), chk.Equals, true)
+	c.Assert(parts.SAS.Verify(credential, "container", "dir/blob"), chk.IsNil)
+
+	// The copy source's signature doesn't appear in logs or errors
+	destination, _ := url.Parse("https://other.blob.core.windows.net/container/blob")
+	p := pipeline.NewPipeline([]pipeline.Factory{pipeline.MethodFactoryMarker(),
+		respondWith(http.StatusInternalServerError, nil, "")}, pipeline.Options{})
+	_, err := azblob.NewBlobURL(*destination, p).StartCopy(context.Background(), source, nil,
+		azblob.BlobAccessConditions{}, azblob.BlobAccessConditions{})
+	c.Assert(err, chk.NotNil)
+	c.Assert(strings.Contains(err.Error(), parts.SAS.Signature), chk.Equals, false)
+	c.Assert(strings.Contains(err.Error(), "sig=REDACTED"), chk.Equals, true)
+}
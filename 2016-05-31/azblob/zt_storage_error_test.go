@@ -0,0 +1,52 @@
+package azblob_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+
+	chk "gopkg.in/check.v1"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/2016-05-31/azblob"
+)
+
+func (s *aztestsSuite) TestStorageErrorRawBody(c *chk.C) {
+	u, _ := url.Parse("http://account.blob.core.windows.net/container/blob")
+	page := "<html><body>Proxy Authentication Required" + strings.Repeat(".", azblob.StorageErrorMaxRawBodyBytes)
+	p := azblob.NewPipeline(azblob.NewAnonymousCredential(), azblob.PipelineOptions{Retry: azblob.RetryOptions{MaxTries: 1},
+		HTTPSender: respondWith(http.StatusBadGateway, nil, page)})
+	blobURL := azblob.NewBlobURL(*u, p)
+
+	_, err := blobURL.Delete(context.Background(), azblob.DeleteSnapshotsOptionNone, azblob.BlobAccessConditions{})
+	serr, ok := err.(azblob.StorageError)
+	c.Assert(ok, chk.Equals, true)
+	c.Assert(string(serr.RawBody()), chk.Equals, page[:azblob.StorageErrorMaxRawBodyBytes]) // Truncated
+	c.Assert(strings.Contains(serr.Error(), "Proxy Authentication Required"), chk.Equals, true)
+
+	// A storage service error is parsed so no raw body is kept
+	p = azblob.NewPipeline(azblob.NewAnonymousCredential(), azblob.PipelineOptions{HTTPSender: respondWith(http.StatusNotFound, nil,
+		"<?xml version=\"1.0\" encoding=\"utf-8\"?><Error><Code>BlobNotFound</Code><Message>Not found</Message></Error>")})
+	_, err = blobURL.WithPipeline(p).Delete(context.Background(), azblob.DeleteSnapshotsOptionNone, azblob.BlobAccessConditions{})
+	serr, ok = err.(azblob.StorageError)
+	c.Assert(ok, chk.Equals, true)
+	c.Assert(serr.ServiceCode(), chk.Equals, azblob.ServiceCodeBlobNotFound)
+	c.Assert(serr.RawBody(), chk.IsNil)
+}
+
+func (s *aztestsSuite) TestStorageErrorRequestID(c *chk.C) {
+	u, _ := url.Parse("http://account.blob.core.windows.net/container/blob")
+	header := http.Header{}
+	header.Set("x-ms-request-id", "service-request-id")
+	p := pipeline.NewPipeline([]pipeline.Factory{azblob.NewUniqueRequestIDPolicyFactory(), pipeline.MethodFactoryMarker(),
+		respondWith(http.StatusInternalServerError, header, "")}, pipeline.Options{})
+	blobURL := azblob.NewBlobURL(*u, p)
+
+	_, err := blobURL.GetPropertiesAndMetadata(context.Background(), azblob.BlobAccessConditions{})
+	serr, ok := err.(azblob.StorageError)
+	c.Assert(ok, chk.Equals, true)
+	c.Assert(serr.RequestID(), chk.Equals, "service-request-id")
+	c.Assert(serr.ClientRequestID(), chk.Not(chk.Equals), "") // From the request since the service didn't echo it
+	c.Assert(strings.Contains(serr.Error(), "RequestID=service-request-id"), chk.Equals, true)
+}
@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"net/url"
 	"os"
 	"runtime"
@@ -72,6 +73,33 @@ func (tm testPipeline) Do(ctx context.Context, methodFactory pipeline.Factory, r
 	return nil, errors.New(testPipelineMessage)
 }
 
+// fakePolicy adapts a func to a pipeline.Factory whose policies call the func for every request. Put it last in a
+// pipeline (or pass it as PipelineOptions.HTTPSender) to stand in for the service in tests that don't need an account.
+type fakePolicy func(ctx context.Context, request pipeline.Request) (pipeline.Response, error)
+
+func (f fakePolicy) New(node pipeline.Node) pipeline.Policy { return f }
+
+func (f fakePolicy) Do(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+	return f(ctx, request)
+}
+
+// respondWith returns a fakePolicy that responds to every request with statusCode, header (nil means none) and body.
+func respondWith(statusCode int, header http.Header, body string) fakePolicy {
+	return func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+		return newFakeResponse(request, statusCode, header, body), nil
+	}
+}
+
+// newFakeResponse returns a response to request with statusCode, a copy of header (nil means none) and body.
+func newFakeResponse(request pipeline.Request, statusCode int, header http.Header, body string) pipeline.Response {
+	h := http.Header{}
+	for k, v := range header {
+		h[k] = v
+	}
+	return &httpResponse{response: &http.Response{StatusCode: statusCode, Header: h,
+		Body: ioutil.NopCloser(strings.NewReader(body)), Request: request.Request}}
+}
+
 // This function generates an entity name by concatenating the passed prefix,
 // the name of the test requesting the entity name, and the minute, second, and nanoseconds of the call.
 // This should make it easy to associate the entities with their test, uniquely identify
@@ -1030,7 +1058,8 @@ func (s *aztestsSuite) TestContainerSetPermissionsACLSinglePolicy(c *chk.C) {
 
 	serviceSASValues := azblob.BlobSASSignatureValues{Version: "2015-04-05",
 		Identifier: "0000", ContainerName: containerName}
-	queryParams := serviceSASValues.NewSASQueryParameters(credentials)
+	queryParams, err := serviceSASValues.NewSASQueryParameters(credentials)
+	c.Assert(err, chk.IsNil)
 	sasURL := bsu.URL()
 	sasURL.RawQuery = queryParams.Encode()
 	sasPipeline := azblob.NewPipeline(azblob.NewAnonymousCredential(), azblob.PipelineOptions{})
@@ -1432,6 +1461,88 @@ func (s *aztestsSuite) TestBlobStartCopyDestEmpty(c *chk.C) {
 	resp.Body().Close()
 }
 
+func (s *aztestsSuite) TestBlobWaitForCopy(c *chk.C) {
+	bsu := getBSU()
+	containerURL, _ := createNewContainer(c, bsu)
+	defer deleteContainer(c, containerURL)
+	blobURL, _ := createNewBlockBlob(c, containerURL)
+	copyBlobURL, _ := getBlockBlobURL(c, containerURL)
+
+	blobCopyResponse, err := copyBlobURL.StartCopy(ctx, blobURL.URL(), nil, azblob.BlobAccessConditions{}, azblob.BlobAccessConditions{})
+	c.Assert(err, chk.IsNil)
+
+	var lastCopied, lastTotal int64
+	waitCtx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	etag, err := copyBlobURL.WaitForCopy(waitCtx, blobCopyResponse.CopyID(), azblob.WaitForCopyOptions{
+		InitialInterval: 100 * time.Millisecond,
+		MaxInterval:     time.Second,
+		Multiplier:      1.5,
+		Progress:        func(bytesCopied, totalBytes int64) { lastCopied, lastTotal = bytesCopied, totalBytes },
+	})
+	c.Assert(err, chk.IsNil)
+	c.Assert(etag, chk.Not(chk.Equals), azblob.ETagNone)
+	c.Assert(lastCopied, chk.Equals, int64(len(blockBlobDefaultData)))
+	c.Assert(lastTotal, chk.Equals, int64(len(blockBlobDefaultData)))
+}
+
+func (s *aztestsSuite) TestCopyBlob(c *chk.C) {
+	bsu := getBSU()
+	containerURL, _ := createNewContainer(c, bsu)
+	defer deleteContainer(c, containerURL)
+	blobURL, _ := createNewBlockBlob(c, containerURL)
+	copyBlobURL, _ := getBlockBlobURL(c, containerURL)
+
+	startedCopyID := ""
+	etag, err := azblob.CopyBlob(ctx, copyBlobURL.BlobURL, blobURL.URL(), azblob.CopyBlobOptions{
+		Metadata: basicMetadata,
+		Started:  func(copyID string) { startedCopyID = copyID },
+	})
+	c.Assert(err, chk.IsNil)
+	c.Assert(startedCopyID, chk.Not(chk.Equals), "")
+
+	props, err := copyBlobURL.GetPropertiesAndMetadata(ctx, azblob.BlobAccessConditions{})
+	c.Assert(err, chk.IsNil)
+	c.Assert(props.ETag(), chk.Equals, etag)
+	c.Assert(props.CopyID(), chk.Equals, startedCopyID)
+	c.Assert(props.NewMetadata(), chk.DeepEquals, basicMetadata)
+}
+
+func (s *aztestsSuite) TestBlobCopyTypedProperties(c *chk.C) {
+	bsu := getBSU()
+	containerURL, _ := createNewContainer(c, bsu)
+	defer deleteContainer(c, containerURL)
+	blobURL, _ := createNewBlockBlob(c, containerURL)
+	copyBlobURL, _ := getBlockBlobURL(c, containerURL)
+
+	resp, err := copyBlobURL.StartCopy(ctx, blobURL.URL(), nil, azblob.BlobAccessConditions{}, azblob.BlobAccessConditions{})
+	c.Assert(err, chk.IsNil)
+	waitForCopy(c, copyBlobURL, resp)
+
+	props, err := copyBlobURL.GetPropertiesAndMetadata(ctx, azblob.BlobAccessConditions{})
+	c.Assert(err, chk.IsNil)
+	bytesCopied, totalBytes := props.CopyProgressBytes()
+	c.Assert(bytesCopied, chk.Equals, int64(len(blockBlobDefaultData)))
+	c.Assert(totalBytes, chk.Equals, int64(len(blockBlobDefaultData)))
+	c.Assert(props.CopyCompletionTime().IsZero(), chk.Equals, false)
+	c.Assert(props.IncrementalCopy(), chk.Equals, false)
+	c.Assert(props.SequenceNumber(), chk.Equals, int64(-1)) // Not a page blob
+	c.Assert(props.RemainingBlockCount(), chk.Equals, int32(-1)) // Not an append blob
+
+	get, err := copyBlobURL.GetBlob(ctx, azblob.BlobRange{}, azblob.BlobAccessConditions{}, false)
+	c.Assert(err, chk.IsNil)
+	defer get.Body().Close()
+	bytesCopied, totalBytes = get.CopyProgressBytes()
+	c.Assert(bytesCopied, chk.Equals, int64(len(blockBlobDefaultData)))
+	c.Assert(totalBytes, chk.Equals, int64(len(blockBlobDefaultData)))
+
+	props, err = blobURL.GetPropertiesAndMetadata(ctx, azblob.BlobAccessConditions{}) // Not a copy destination
+	c.Assert(err, chk.IsNil)
+	bytesCopied, totalBytes = props.CopyProgressBytes()
+	c.Assert(bytesCopied, chk.Equals, int64(0))
+	c.Assert(totalBytes, chk.Equals, int64(0))
+}
+
 func (s *aztestsSuite) TestBlobStartCopyMetadata(c *chk.C) {
 	bsu := getBSU()
 	containerURL, _ := createNewContainer(c, bsu)
@@ -1552,7 +1663,8 @@ func (s *aztestsSuite) TestBlobStartCopyUsingSASSrc(c *chk.C) {
 	serviceSASValues := azblob.BlobSASSignatureValues{Version: "2015-04-05", StartTime: time.Now().Add(-1 * time.Hour).UTC(),
 		ExpiryTime: time.Now().Add(time.Hour).UTC(), Permissions: azblob.BlobSASPermissions{Read: true, Write: true}.String(),
 		ContainerName: containerName, BlobName: blobName}
-	queryParams := serviceSASValues.NewSASQueryParameters(credentials)
+	queryParams, err := serviceSASValues.NewSASQueryParameters(credentials)
+	c.Assert(err, chk.IsNil)
 
 	// Create URLs to the destination blob with sas parameters
 	sasURL := blobURL.URL()
@@ -1595,7 +1707,8 @@ func (s *aztestsSuite) TestBlobStartCopyUsingSASDest(c *chk.C) {
 	serviceSASValues := azblob.BlobSASSignatureValues{ExpiryTime: time.Now().Add(time.Hour).UTC(),
 		Permissions: azblob.BlobSASPermissions{Read: true, Write: true, Create: true}.String(), ContainerName: containerName, BlobName: blobName}
 	credentials := azblob.NewSharedKeyCredential(os.Getenv("ACCOUNT_NAME"), os.Getenv("ACCOUNT_KEY"))
-	queryParams := serviceSASValues.NewSASQueryParameters(credentials)
+	queryParams, err := serviceSASValues.NewSASQueryParameters(credentials)
+	c.Assert(err, chk.IsNil)
 
 	// Create destination container
 	bsu2,err := getAlternateBSU()
@@ -1613,7 +1726,8 @@ func (s *aztestsSuite) TestBlobStartCopyUsingSASDest(c *chk.C) {
 	copyServiceSASvalues := azblob.BlobSASSignatureValues{StartTime: time.Now().Add(-1 * time.Hour).UTC(),
 		ExpiryTime: time.Now().Add(time.Hour).UTC(), Permissions: azblob.BlobSASPermissions{Read: true, Write: true}.String(),
 		ContainerName: copyContainerName, BlobName: copyBlobName}
-	copyQueryParams := copyServiceSASvalues.NewSASQueryParameters(credentials)
+	copyQueryParams, err := copyServiceSASvalues.NewSASQueryParameters(credentials)
+	c.Assert(err, chk.IsNil)
 
 	// Generate anonymous URL to destination with SAS
 	anonURL := bsu2.URL()
@@ -2281,6 +2395,26 @@ func (s *aztestsSuite) TestBlobDownloadDataContentMD5(c *chk.C) {
 	c.Assert(err, chk.IsNil)
 	mdf := md5.Sum([]byte(blockBlobDefaultData)[10:13])
 	c.Assert(resp.ContentMD5(), chk.Equals, mdf)
+	data, err := ioutil.ReadAll(resp.Body()) // The body is verified against the range's MD5
+	c.Assert(err, chk.IsNil)
+	c.Assert(string(data), chk.Equals, blockBlobDefaultData[10:13])
+}
+
+func (s *aztestsSuite) TestBlobDownloadDataContentMD5RangeTooLarge(c *chk.C) {
+	u, _ := url.Parse("http://account.blob.core.windows.net/container/blob")
+	tries := 0
+	service := fakePolicy(func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+		tries++
+		return newFakeResponse(request, http.StatusPartialContent, nil, ""), nil
+	})
+	blobURL := azblob.NewBlobURL(*u, pipeline.NewPipeline([]pipeline.Factory{pipeline.MethodFactoryMarker(), service}, pipeline.Options{}))
+
+	for _, count := range []int64{azblob.CountToEnd, azblob.BlobMaxRangeGetContentMD5Bytes + 1} {
+		resp, err := blobURL.GetBlob(ctx, azblob.BlobRange{Count: count}, azblob.BlobAccessConditions{}, true)
+		c.Assert(err, chk.Equals, azblob.ErrRangeTooLargeForMD5)
+		c.Assert(resp, chk.IsNil)
+	}
+	c.Assert(tries, chk.Equals, 0) // No request was sent
 }
 
 func (s *aztestsSuite) TestBlobDownloadDataIfModifiedSinceTrue(c *chk.C) {
@@ -2305,10 +2439,11 @@ func (s *aztestsSuite) TestBlobDownloadDataIfModifiedSinceFalse(c *chk.C) {
 
 	currentTime := getRelativeTimeGMT(10)
 
-	_, err := blobURL.GetBlob(ctx, azblob.BlobRange{},
+	resp, err := blobURL.GetBlob(ctx, azblob.BlobRange{},
 		azblob.BlobAccessConditions{HTTPAccessConditions: azblob.HTTPAccessConditions{IfModifiedSince: currentTime}}, false)
-	serr := err.(azblob.StorageError)
-	c.Assert(serr.Response().StatusCode, chk.Equals, 304) // The server does not return the error in the body even though it is a GET
+	c.Assert(err, chk.IsNil) // A 304 means the caller's copy is current; it isn't an error
+	c.Assert(resp.StatusCode(), chk.Equals, 304)
+	c.Assert(resp.NotModified(), chk.Equals, true)
 }
 
 func (s *aztestsSuite) TestBlobDownloadDataIfUnmodifiedSinceTrue(c *chk.C) {
@@ -2399,10 +2534,13 @@ func (s *aztestsSuite) TestBlobDownloadDataIfNoneMatchFalse(c *chk.C) {
 	c.Assert(err, chk.IsNil)
 	etag := resp.ETag()
 
-	_, err = blobURL.GetBlob(ctx, azblob.BlobRange{},
+	getResp, err := blobURL.GetBlob(ctx, azblob.BlobRange{},
 		azblob.BlobAccessConditions{HTTPAccessConditions: azblob.HTTPAccessConditions{IfNoneMatch: etag}}, false)
-	serr := err.(azblob.StorageError)
-	c.Assert(serr.Response().StatusCode, chk.Equals, 304) // The server does not return the error in the body even though it is a GET
+	c.Assert(err, chk.IsNil) // A 304 means the caller's copy is current; it isn't an error
+	c.Assert(getResp.NotModified(), chk.Equals, true)
+	data, err := ioutil.ReadAll(getResp.Body())
+	c.Assert(err, chk.IsNil)
+	c.Assert(data, chk.HasLen, 0)
 }
 
 func (s *aztestsSuite) TestBlobDeleteNonExistant(c *chk.C) {
@@ -2431,6 +2569,24 @@ func (s *aztestsSuite) TestBlobDeleteSnapshot(c *chk.C) {
 	validateBlobDeleted(c, snapshotURL)
 }
 
+func (s *aztestsSuite) TestBlobDeleteSnapshotWithOptionPanics(c *chk.C) {
+	bsu := getBSU()
+	containerURL, _ := getContainerURL(c, bsu)
+	blobURL, _ := getBlockBlobURL(c, containerURL)
+	snapshotURL := blobURL.WithSnapshot(time.Now().UTC())
+
+	for _, option := range []azblob.DeleteSnapshotsOptionType{azblob.DeleteSnapshotsOptionInclude, azblob.DeleteSnapshotsOptionOnly} {
+		c.Assert(func() { snapshotURL.Delete(ctx, option, azblob.BlobAccessConditions{}) },
+			chk.Panics, "deleteOptions must be DeleteSnapshotsOptionNone when deleting a snapshot")
+	}
+
+	// An untyped string that isn't one of the constants is caught before it's sent
+	c.Assert(func() { blobURL.Delete(ctx, "Include", azblob.BlobAccessConditions{}) },
+		chk.Panics, "deleteOptions must be DeleteSnapshotsOptionNone, DeleteSnapshotsOptionInclude, or DeleteSnapshotsOptionOnly")
+	c.Assert(func() { blobURL.ToAppendBlobURL().Delete(ctx, "all", azblob.BlobAccessConditions{}) },
+		chk.Panics, "deleteOptions must be DeleteSnapshotsOptionNone, DeleteSnapshotsOptionInclude, or DeleteSnapshotsOptionOnly")
+}
+
 func (s *aztestsSuite) TestBlobDeleteSnapshotsInclude(c *chk.C) {
 	bsu := getBSU()
 	containerURL, _ := createNewContainer(c, bsu)
@@ -2474,6 +2630,8 @@ func (s *aztestsSuite) TestBlobDeleteSnapshotsNoneWithSnapshots(c *chk.C) {
 	c.Assert(err, chk.IsNil)
 	_, err = blobURL.Delete(ctx, azblob.DeleteSnapshotsOptionNone, azblob.BlobAccessConditions{})
 	validateStorageError(c, err, azblob.ServiceCodeSnapshotsPresent)
+	_, ok := err.(*azblob.SnapshotsPresentError)
+	c.Assert(ok, chk.Equals, true)
 }
 
 func validateBlobDeleted(c *chk.C, blobURL azblob.BlockBlobURL) {
@@ -4064,6 +4222,21 @@ func (s *aztestsSuite) TestBlobCreatePageSequenceInvalid(c *chk.C) {
 
 }
 
+func (s *aztestsSuite) TestBlobCreatePageSequenceNumber(c *chk.C) {
+	bsu := getBSU()
+	containerURL, _ := createNewContainer(c, bsu)
+	defer deleteContainer(c, containerURL)
+	blobURL, _ := getPageBlobURL(c, containerURL)
+
+	_, err := blobURL.Create(ctx, azblob.PageBlobPageBytes, 7, nil, azblob.BlobHTTPHeaders{}, azblob.BlobAccessConditions{})
+	c.Assert(err, chk.IsNil)
+
+	resp, err := blobURL.GetPropertiesAndMetadata(ctx, azblob.BlobAccessConditions{})
+	c.Assert(err, chk.IsNil)
+	c.Assert(resp.BlobSequenceNumber(), chk.Equals, "7")
+	c.Assert(resp.SequenceNumber(), chk.Equals, int64(7))
+}
+
 func (s *aztestsSuite) TestBlobCreatePageMetadataNonEmpty(c *chk.C) {
 	bsu := getBSU()
 	containerURL, _ := createNewContainer(c, bsu)
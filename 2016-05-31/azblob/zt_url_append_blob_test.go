@@ -1,8 +1,14 @@
 package azblob_test
 
 import (
+	"bytes"
 	"context"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
 
+	"github.com/Azure/azure-pipeline-go/pipeline"
 	"github.com/Azure/azure-storage-blob-go/2016-05-31/azblob"
 	chk "gopkg.in/check.v1" // go get gopkg.in/check.v1
 )
@@ -38,4 +44,99 @@ func (b *AppendBlobURLSuite) TestAppendBlock(c *chk.C) {
 	c.Assert(err, chk.IsNil)
 	c.Assert(appendResp.BlobAppendOffset(), chk.Equals, "1024")
 	c.Assert(appendResp.BlobCommittedBlockCount(), chk.Equals, "2")
+	c.Assert(appendResp.RemainingBlockCount(), chk.Equals, int32(azblob.AppendBlobMaxBlocks-2))
+
+	_, err = blob.AppendBlock(context.Background(), getReaderToRandomBytes(azblob.AppendBlobMaxAppendBlockBytes+1), azblob.BlobAccessConditions{})
+	c.Assert(err, chk.Equals, azblob.ErrAppendBlockTooLarge)
+
+	props, err := blob.GetPropertiesAndMetadata(context.Background(), azblob.BlobAccessConditions{})
+	c.Assert(err, chk.IsNil)
+	c.Assert(props.RemainingBlockCount(), chk.Equals, int32(azblob.AppendBlobMaxBlocks-2)) // The oversized block wasn't sent
+}
+
+func (b *AppendBlobURLSuite) TestCreateIfNotExistsAndDownloadTail(c *chk.C) {
+	bsu := getBSU()
+	container, _ := createNewContainer(c, bsu)
+	defer delContainer(c, container)
+
+	blob := container.NewAppendBlobURL(generateBlobName())
+
+	resp, created, err := blob.CreateIfNotExists(context.Background(), nil, azblob.BlobHTTPHeaders{})
+	c.Assert(err, chk.IsNil)
+	c.Assert(created, chk.Equals, true)
+	c.Assert(resp.StatusCode(), chk.Equals, 201)
+
+	_, err = blob.AppendBlock(context.Background(), bytes.NewReader([]byte("line1\nline2\n")), azblob.BlobAccessConditions{})
+	c.Assert(err, chk.IsNil)
+
+	resp, created, err = blob.CreateIfNotExists(context.Background(), nil, azblob.BlobHTTPHeaders{})
+	c.Assert(err, chk.IsNil)
+	c.Assert(created, chk.Equals, false) // The blob already existed and was left unchanged
+	c.Assert(resp, chk.IsNil)
+
+	getResp, err := blob.DownloadTail(context.Background(), 6, azblob.BlobAccessConditions{})
+	c.Assert(err, chk.IsNil)
+	data, err := ioutil.ReadAll(getResp.Body())
+	c.Assert(err, chk.IsNil)
+	c.Assert(string(data), chk.Equals, "line2\n")
+
+	getResp, err = blob.DownloadTail(context.Background(), 100, azblob.BlobAccessConditions{})
+	c.Assert(err, chk.IsNil)
+	data, err = ioutil.ReadAll(getResp.Body())
+	c.Assert(err, chk.IsNil)
+	c.Assert(string(data), chk.Equals, "line1\nline2\n") // The blob is shorter than n
+}
+
+func (b *AppendBlobURLSuite) TestCreateIfNoneMatchRace(c *chk.C) {
+	bsu := getBSU()
+	container, _ := createNewContainer(c, bsu)
+	defer delContainer(c, container)
+
+	blob := container.NewAppendBlobURL(generateBlobName())
+	ac := azblob.BlobAccessConditions{HTTPAccessConditions: azblob.HTTPAccessConditions{IfNoneMatch: azblob.ETagAny}}
+
+	const creators = 2
+	errs := make([]error, creators)
+	wg := sync.WaitGroup{}
+	for i := 0; i < creators; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = blob.Create(context.Background(), nil, azblob.BlobHTTPHeaders{}, ac)
+		}(i)
+	}
+	wg.Wait()
+
+	succeeded := 0
+	for _, err := range errs {
+		if err == nil {
+			succeeded++
+			continue
+		}
+		serr, ok := err.(azblob.StorageError)
+		c.Assert(ok, chk.Equals, true)
+		c.Assert(serr.ServiceCode() == azblob.ServiceCodeBlobAlreadyExists || serr.ServiceCode() == azblob.ServiceCodeConditionNotMet,
+			chk.Equals, true) // 409 or, if the requests overlap, 412
+	}
+	c.Assert(succeeded, chk.Equals, 1)
+}
+
+func (b *AppendBlobURLSuite) TestCreateIfNotExistsOnlyIgnoresBlobAlreadyExists(c *chk.C) {
+	u, _ := url.Parse("http://account.blob.core.windows.net/container/blob")
+	errorBody := func(code azblob.ServiceCodeType) string {
+		return "<?xml version=\"1.0\" encoding=\"utf-8\"?><Error><Code>" + string(code) + "</Code></Error>"
+	}
+
+	service := respondWith(http.StatusConflict, nil, errorBody(azblob.ServiceCodeBlobAlreadyExists))
+	blob := azblob.NewAppendBlobURL(*u, pipeline.NewPipeline([]pipeline.Factory{pipeline.MethodFactoryMarker(), service}, pipeline.Options{}))
+	resp, created, err := blob.CreateIfNotExists(context.Background(), nil, azblob.BlobHTTPHeaders{})
+	c.Assert(err, chk.IsNil)
+	c.Assert(created, chk.Equals, false)
+	c.Assert(resp, chk.IsNil)
+
+	service = respondWith(http.StatusPreconditionFailed, nil, errorBody(azblob.ServiceCodeConditionNotMet))
+	blob = azblob.NewAppendBlobURL(*u, pipeline.NewPipeline([]pipeline.Factory{pipeline.MethodFactoryMarker(), service}, pipeline.Options{}))
+	_, created, err = blob.CreateIfNotExists(context.Background(), nil, azblob.BlobHTTPHeaders{})
+	c.Assert(err, chk.NotNil)
+	c.Assert(created, chk.Equals, false)
 }
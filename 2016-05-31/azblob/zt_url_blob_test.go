@@ -8,7 +8,9 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 
+	"github.com/Azure/azure-pipeline-go/pipeline"
 	"github.com/Azure/azure-storage-blob-go/2016-05-31/azblob"
 
 	chk "gopkg.in/check.v1" // go get gopkg.in/check.v1
@@ -299,7 +301,7 @@ func (b *BlobURLSuite) TestLeaseRenewChangeBreak(c *chk.C) {
 	c.Assert(resp.ETag(), chk.Not(chk.Equals), azblob.ETagNone)
 	c.Assert(resp.LastModified().IsZero(), chk.Equals, false)
 	c.Assert(resp.LeaseID(), chk.Equals, "")
-	c.Assert(resp.LeaseTime(), chk.Not(chk.Equals), "")
+	c.Assert(resp.LeaseTime() >= 0 && resp.LeaseTime() <= 5, chk.Equals, true) // The lease is broken within the 5s break period
 	c.Assert(resp.RequestID(), chk.Not(chk.Equals), "")
 	c.Assert(resp.Version(), chk.Not(chk.Equals), "")
 
@@ -362,3 +364,258 @@ func (b *BlobURLSuite) TestGetBlobRange(c *chk.C) {
 	c.Assert(resp.Response().StatusCode, chk.Equals, 206)
 	c.Assert(resp.Version(), chk.Not(chk.Equals), "")
 }
+
+func (b *BlobURLSuite) TestPromoteSnapshot(c *chk.C) {
+	bsu := getBSU()
+	container, _ := createNewContainer(c, bsu)
+	defer delContainer(c, container)
+
+	blob, _ := createNewBlockBlob(c, container)
+	snapshotResp, err := blob.CreateSnapshot(context.Background(), nil, azblob.BlobAccessConditions{})
+	c.Assert(err, chk.IsNil)
+
+	_, err = blob.PutBlob(context.Background(), bytes.NewReader([]byte("NewData")), azblob.BlobHTTPHeaders{}, nil, azblob.BlobAccessConditions{})
+	c.Assert(err, chk.IsNil)
+
+	etag, err := blob.PromoteSnapshot(context.Background(), snapshotResp.Snapshot(), azblob.BlobAccessConditions{}, azblob.BlobAccessConditions{})
+	c.Assert(err, chk.IsNil)
+	c.Assert(etag, chk.Not(chk.Equals), azblob.ETagNone)
+
+	resp, err := blob.GetBlob(context.Background(), azblob.BlobRange{}, azblob.BlobAccessConditions{}, false)
+	c.Assert(err, chk.IsNil)
+	c.Assert(resp.ETag(), chk.Equals, etag)
+	data, err := ioutil.ReadAll(resp.Response().Body)
+	c.Assert(err, chk.IsNil)
+	c.Assert(string(data), chk.Equals, blockBlobDefaultData) // The base blob has the snapshot's contents
+}
+
+func (b *BlobURLSuite) TestDeleteSnapshotsKeepsBaseBlob(c *chk.C) {
+	bsu := getBSU()
+	container, _ := createNewContainer(c, bsu)
+	defer delContainer(c, container)
+
+	blob, _ := createNewBlockBlob(c, container)
+	for i := 0; i < 2; i++ {
+		_, err := blob.CreateSnapshot(context.Background(), nil, azblob.BlobAccessConditions{})
+		c.Assert(err, chk.IsNil)
+	}
+
+	_, err := blob.DeleteSnapshots(context.Background(), azblob.BlobAccessConditions{})
+	c.Assert(err, chk.IsNil)
+
+	blobs, err := container.ListBlobs(context.Background(), azblob.Marker{}, azblob.ListBlobsOptions{Details: azblob.BlobListingDetails{Snapshots: true}})
+	c.Assert(err, chk.IsNil)
+	c.Assert(blobs.Blobs.Blob, chk.HasLen, 1)
+	c.Assert(blobs.Blobs.Blob[0].Snapshot.IsZero(), chk.Equals, true) // Only the base blob survives
+}
+
+func (b *BlobURLSuite) TestListSnapshots(c *chk.C) {
+	bsu := getBSU()
+	container, _ := createNewContainer(c, bsu)
+	defer delContainer(c, container)
+
+	blob, name := createNewBlockBlob(c, container)
+	other, _ := createBlockBlobWithPrefix(c, container, name) // Shares the blob's name as a prefix
+	_, err := other.CreateSnapshot(context.Background(), nil, azblob.BlobAccessConditions{})
+	c.Assert(err, chk.IsNil)
+	snapshotResp, err := blob.CreateSnapshot(context.Background(), nil, azblob.BlobAccessConditions{})
+	c.Assert(err, chk.IsNil)
+
+	snapshots, err := blob.ListSnapshots(context.Background())
+	c.Assert(err, chk.IsNil)
+	c.Assert(snapshots, chk.HasLen, 1)
+	c.Assert(snapshots[0].Equal(snapshotResp.Snapshot()), chk.Equals, true)
+}
+
+func (b *BlobURLSuite) TestBlobRangeHeader(c *chk.C) {
+	testCases := []struct {
+		r        azblob.BlobRange
+		expected string
+	}{
+		{azblob.BlobRange{}, ""},
+		{azblob.BlobRange{Offset: 10, Count: azblob.CountToEnd}, "bytes=10-"},
+		{azblob.BlobRange{Offset: 0, Count: 512}, "bytes=0-511"},
+		{azblob.BlobRange{Offset: 512, Count: 1}, "bytes=512-512"},
+	}
+	for _, tc := range testCases {
+		c.Assert(tc.r.Header(), chk.Equals, tc.expected)
+	}
+	c.Assert(func() { azblob.BlobRange{Offset: -1}.Header() }, chk.Panics, "The blob's range Offset must be >= 0; to read the end of a blob, use DownloadTail")
+	c.Assert(func() { azblob.BlobRange{Count: -1}.Header() }, chk.Panics, "The blob's range Count must be >= 0")
+}
+
+func (b *BlobURLSuite) TestClientRequestID(c *chk.C) {
+	bsu := getBSU()
+	container, _ := createNewContainer(c, bsu)
+	defer delContainer(c, container)
+
+	blob, _ := createNewBlockBlob(c, container)
+	resp, err := blob.GetPropertiesAndMetadata(context.Background(), azblob.BlobAccessConditions{})
+	c.Assert(err, chk.IsNil)
+	c.Assert(azblob.ClientRequestID(resp), chk.Not(chk.Equals), "") // Generated by the pipeline
+
+	_, err = container.NewBlobURL(generateBlobName()).GetPropertiesAndMetadata(context.Background(), azblob.BlobAccessConditions{})
+	se, ok := err.(azblob.StorageError)
+	c.Assert(ok, chk.Equals, true)
+	c.Assert(azblob.ClientRequestID(se), chk.Not(chk.Equals), "") // Also available for failed requests
+}
+
+func (b *BlobURLSuite) TestMergeMetadata(c *chk.C) {
+	bsu := getBSU()
+	container, _ := createNewContainer(c, bsu)
+	defer delContainer(c, container)
+
+	blob, _ := createNewBlockBlob(c, container)
+	_, err := blob.SetMetadata(context.Background(), azblob.Metadata{"keep": "1", "update": "1", "remove": "1"}, azblob.BlobAccessConditions{})
+	c.Assert(err, chk.IsNil)
+
+	_, err = blob.MergeMetadata(context.Background(), azblob.Metadata{"update": "2", "remove": "", "add": "3"}, azblob.BlobAccessConditions{})
+	c.Assert(err, chk.IsNil)
+
+	props, err := blob.GetPropertiesAndMetadata(context.Background(), azblob.BlobAccessConditions{})
+	c.Assert(err, chk.IsNil)
+	c.Assert(props.NewMetadata(), chk.DeepEquals, azblob.Metadata{"keep": "1", "update": "2", "add": "3"})
+}
+
+func (b *BlobURLSuite) TestLeaseTypedValues(c *chk.C) {
+	bsu := getBSU()
+	container, _ := createNewContainer(c, bsu)
+	defer delContainer(c, container)
+
+	blob, _ := createNewBlockBlob(c, container)
+	_, err := blob.AcquireInfiniteLease(context.Background(), "", azblob.HTTPAccessConditions{})
+	c.Assert(err, chk.IsNil)
+
+	props, err := blob.GetPropertiesAndMetadata(context.Background(), azblob.BlobAccessConditions{})
+	c.Assert(err, chk.IsNil)
+	c.Assert(props.LeaseStatus(), chk.Equals, azblob.LeaseStatusLocked)
+	c.Assert(props.LeaseState(), chk.Equals, azblob.LeaseStateLeased)
+	c.Assert(props.LeaseDuration(), chk.Equals, azblob.LeaseDurationInfinite)
+
+	getResp, err := blob.GetBlob(context.Background(), azblob.BlobRange{}, azblob.BlobAccessConditions{}, false)
+	c.Assert(err, chk.IsNil)
+	getResp.Body().Close()
+	c.Assert(getResp.LeaseState(), chk.Equals, azblob.LeaseStateLeased)
+
+	blobs, err := container.ListBlobs(context.Background(), azblob.Marker{}, azblob.ListBlobsOptions{})
+	c.Assert(err, chk.IsNil)
+	c.Assert(blobs.Blobs.Blob, chk.HasLen, 1)
+	c.Assert(blobs.Blobs.Blob[0].Properties.LeaseState, chk.Equals, azblob.LeaseStateLeased)
+	c.Assert(blobs.Blobs.Blob[0].Properties.LeaseDuration, chk.Equals, azblob.LeaseDurationInfinite)
+}
+
+func (b *BlobURLSuite) TestAcquireLeaseInvalidDuration(c *chk.C) {
+	bsu := getBSU()
+	containerURL, _ := getContainerURL(c, bsu)
+	blobURL, _ := getBlockBlobURL(c, containerURL)
+
+	for _, duration := range []int32{-2, 0, 14, 61} {
+		_, err := blobURL.AcquireLease(context.Background(), "", duration, azblob.HTTPAccessConditions{})
+		c.Assert(err, chk.Equals, azblob.ErrInvalidLeaseDuration)
+		_, err = containerURL.AcquireLease(context.Background(), "", duration, azblob.HTTPAccessConditions{})
+		c.Assert(err, chk.Equals, azblob.ErrInvalidLeaseDuration)
+	}
+}
+
+func (b *BlobURLSuite) TestGetProperties(c *chk.C) {
+	bsu := getBSU()
+	container, _ := createNewContainer(c, bsu)
+	defer delContainer(c, container)
+
+	blob, _ := createNewBlockBlob(c, container)
+	props, err := blob.GetProperties(ctx, azblob.BlobAccessConditions{})
+	c.Assert(err, chk.IsNil)
+	c.Assert(props.ContentLength(), chk.Equals, int64(len(blockBlobDefaultData)))
+	c.Assert(props.ETag(), chk.Not(chk.Equals), azblob.ETagNone)
+	c.Assert(props.BlobType(), chk.Equals, azblob.BlobBlockBlob)
+
+	_, err = container.NewBlobURL(generateBlobName()).GetProperties(ctx, azblob.BlobAccessConditions{})
+	c.Assert(err.(azblob.StorageError).Response().StatusCode, chk.Equals, http.StatusNotFound)
+}
+
+func (b *BlobURLSuite) TestBreakLeaseSendsBreakPeriod(c *chk.C) {
+	u, _ := url.Parse("http://account.blob.core.windows.net/container/blob")
+	var header http.Header
+	recorder := recordHeader(&header)
+	blobURL := azblob.NewBlobURL(*u, pipeline.NewPipeline([]pipeline.Factory{pipeline.MethodFactoryMarker(), recorder}, pipeline.Options{}))
+
+	blobURL.BreakLease(ctx, "", 10, azblob.HTTPAccessConditions{}) // The recorder's 200 isn't a valid break response; only the request matters
+	c.Assert(header.Get("x-ms-lease-break-period"), chk.Equals, "10")
+	blobURL.BreakLease(ctx, "", azblob.LeaseBreakNaturally, azblob.HTTPAccessConditions{})
+	c.Assert(header.Get("x-ms-lease-break-period"), chk.Equals, "")
+
+	header = nil
+	_, err := blobURL.BreakLease(ctx, "", 61, azblob.HTTPAccessConditions{})
+	c.Assert(err, chk.Equals, azblob.ErrInvalidLeaseBreakPeriod)
+	c.Assert(header, chk.IsNil) // The request wasn't sent
+	containerURL := azblob.NewContainerURL(*u, pipeline.NewPipeline([]pipeline.Factory{pipeline.MethodFactoryMarker(), recorder}, pipeline.Options{}))
+	_, err = containerURL.BreakLease(ctx, "", -2, azblob.HTTPAccessConditions{})
+	c.Assert(err, chk.Equals, azblob.ErrInvalidLeaseBreakPeriod)
+	c.Assert(header, chk.IsNil)
+}
+
+func (b *BlobURLSuite) TestGetResponseBlobContentLength(c *chk.C) {
+	u, _ := url.Parse("http://account.blob.core.windows.net/container/blob")
+	testCases := []struct {
+		statusCode    int
+		contentRange  string
+		contentLength string
+		expected      int64
+	}{
+		{http.StatusPartialContent, "bytes 0-99/1000", "100", 1000},
+		{http.StatusPartialContent, "bytes 900-999/1000", "100", 1000},
+		{http.StatusOK, "", "1000", 1000}, // Not a ranged GET
+		{http.StatusPartialContent, "bytes 0-99/*", "100", -1},
+		{http.StatusPartialContent, "bytes 0-99", "100", -1},
+		{http.StatusPartialContent, "bytes 99-0/1000", "100", -1},
+		{http.StatusPartialContent, "bytes 0-999/1000", "1000", 1000},
+		{http.StatusPartialContent, "bytes 0-1000/1000", "1001", -1},
+		{http.StatusPartialContent, "items 0-99/1000", "100", -1},
+	}
+	for _, tc := range testCases {
+		header := http.Header{}
+		header.Set("Content-Length", tc.contentLength)
+		if tc.contentRange != "" {
+			header.Set("Content-Range", tc.contentRange)
+		}
+		p := pipeline.NewPipeline([]pipeline.Factory{pipeline.MethodFactoryMarker(),
+			respondWith(tc.statusCode, header, "")}, pipeline.Options{})
+		resp, err := azblob.NewBlobURL(*u, p).GetBlob(ctx, azblob.BlobRange{}, azblob.BlobAccessConditions{}, false)
+		c.Assert(err, chk.IsNil)
+		c.Assert(resp.BlobContentLength(), chk.Equals, tc.expected, chk.Commentf("Content-Range %q", tc.contentRange))
+	}
+}
+
+func (b *BlobURLSuite) TestWaitForCopyDetectsReplacedCopy(c *chk.C) {
+	u, _ := url.Parse("http://account.blob.core.windows.net/container/blob")
+	header := http.Header{}
+	header.Set("x-ms-copy-id", "newer-copy")
+	header.Set("x-ms-copy-status", string(azblob.CopyStatusSuccess))
+	p := pipeline.NewPipeline([]pipeline.Factory{pipeline.MethodFactoryMarker(),
+		respondWith(http.StatusOK, header, "")}, pipeline.Options{})
+
+	// The newer copy succeeded but that says nothing about the copy being waited for
+	_, err := azblob.NewBlobURL(*u, p).WaitForCopy(ctx, "my-copy", azblob.WaitForCopyOptions{})
+	c.Assert(err, chk.NotNil)
+	copyErr, ok := err.(*azblob.CopyFailedError)
+	c.Assert(ok, chk.Equals, true)
+	c.Assert(copyErr.CopyID, chk.Equals, "my-copy")
+	c.Assert(copyErr.CurrentCopyID, chk.Equals, "newer-copy")
+
+	etag, err := azblob.NewBlobURL(*u, p).WaitForCopy(ctx, "newer-copy", azblob.WaitForCopyOptions{})
+	c.Assert(err, chk.IsNil)
+	c.Assert(etag, chk.Equals, azblob.ETagNone) // The fake service returns no ETag
+}
+
+func (b *BlobURLSuite) TestDeleteBlobWithSnapshotsExplainsOption(c *chk.C) {
+	u, _ := url.Parse("http://account.blob.core.windows.net/container/blob")
+	p := pipeline.NewPipeline([]pipeline.Factory{pipeline.MethodFactoryMarker(), respondWith(http.StatusConflict, nil,
+		"<?xml version=\"1.0\" encoding=\"utf-8\"?><Error><Code>SnapshotsPresent</Code>"+
+			"<Message>This operation is not permitted because the blob has snapshots.</Message></Error>")}, pipeline.Options{})
+
+	_, err := azblob.NewBlobURL(*u, p).Delete(ctx, azblob.DeleteSnapshotsOptionNone, azblob.BlobAccessConditions{})
+	serr, ok := err.(*azblob.SnapshotsPresentError)
+	c.Assert(ok, chk.Equals, true)
+	c.Assert(serr.ServiceCode(), chk.Equals, azblob.ServiceCodeSnapshotsPresent)
+	c.Assert(err, chk.ErrorMatches, "(?s)the blob has snapshots.*DeleteSnapshotsOptionInclude.*DeleteSnapshotsOptionOnly.*")
+}
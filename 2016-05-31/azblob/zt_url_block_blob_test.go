@@ -4,7 +4,12 @@ import (
 	"context"
 	"encoding/base64"
 	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
 
+	"github.com/Azure/azure-pipeline-go/pipeline"
 	"github.com/Azure/azure-storage-blob-go/2016-05-31/azblob"
 	chk "gopkg.in/check.v1" // go get gopkg.in/check.v1
 )
@@ -66,3 +71,88 @@ func (b *BlockBlobURLSuite) TestPutGetBlocks(c *chk.C) {
 	c.Assert(blockList.CommittedBlocks, chk.HasLen, 1)
 	c.Assert(blockList.UncommittedBlocks, chk.HasLen, 0)
 }
+
+func (b *BlockBlobURLSuite) TestGetBlockListSnapshot(c *chk.C) {
+	bsu := getBSU()
+	container, _ := createNewContainer(c, bsu)
+	defer delContainer(c, container)
+
+	blob := container.NewBlockBlobURL(generateBlobName())
+	commit := func(ids ...string) {
+		for _, id := range ids {
+			_, err := blob.PutBlock(ctx, id, getReaderToRandomBytes(1024), azblob.LeaseAccessConditions{})
+			c.Assert(err, chk.IsNil)
+		}
+		_, err := blob.PutBlockList(ctx, ids, nil, azblob.BlobHTTPHeaders{}, azblob.BlobAccessConditions{})
+		c.Assert(err, chk.IsNil)
+	}
+
+	commit(azblob.NewBlockID(0))
+	snapResp, err := blob.CreateSnapshot(ctx, azblob.Metadata{}, azblob.BlobAccessConditions{})
+	c.Assert(err, chk.IsNil)
+	commit(azblob.NewBlockID(0), azblob.NewBlockID(1))
+
+	// The base blob reports both blocks; the snapshot still reports only the block committed when it was taken
+	baseList, err := blob.GetBlockList(ctx, azblob.BlockListCommitted, azblob.LeaseAccessConditions{})
+	c.Assert(err, chk.IsNil)
+	c.Assert(baseList.CommittedBlocks, chk.HasLen, 2)
+
+	snapList, err := blob.WithSnapshot(snapResp.Snapshot()).GetBlockList(ctx, azblob.BlockListCommitted, azblob.LeaseAccessConditions{})
+	c.Assert(err, chk.IsNil)
+	c.Assert(snapList.CommittedBlocks, chk.HasLen, 1)
+	c.Assert(snapList.CommittedBlocks[0].Name, chk.Equals, azblob.NewBlockID(0))
+}
+
+func (b *BlockBlobURLSuite) TestHasUncommittedBlocks(c *chk.C) {
+	bsu := getBSU()
+	container, _ := createNewContainer(c, bsu)
+	defer delContainer(c, container)
+
+	blob := container.NewBlockBlobURL(generateBlobName())
+	has, err := blob.HasUncommittedBlocks(ctx, azblob.LeaseAccessConditions{})
+	c.Assert(err, chk.IsNil)
+	c.Assert(has, chk.Equals, false) // The blob doesn't exist
+
+	_, err = blob.PutBlock(ctx, azblob.NewBlockID(0), getReaderToRandomBytes(1024), azblob.LeaseAccessConditions{})
+	c.Assert(err, chk.IsNil)
+	_, err = blob.GetPropertiesAndMetadata(ctx, azblob.BlobAccessConditions{})
+	c.Assert(err, chk.NotNil) // A blob with only uncommitted blocks doesn't exist yet
+	has, err = blob.HasUncommittedBlocks(ctx, azblob.LeaseAccessConditions{})
+	c.Assert(err, chk.IsNil)
+	c.Assert(has, chk.Equals, true)
+
+	_, err = blob.PutBlockList(ctx, []string{azblob.NewBlockID(0)}, nil, azblob.BlobHTTPHeaders{}, azblob.BlobAccessConditions{})
+	c.Assert(err, chk.IsNil)
+	has, err = blob.HasUncommittedBlocks(ctx, azblob.LeaseAccessConditions{})
+	c.Assert(err, chk.IsNil)
+	c.Assert(has, chk.Equals, false)
+}
+
+func (b *BlockBlobURLSuite) TestBlockID(c *chk.C) {
+	for _, index := range []int{0, 1, 255, azblob.BlockBlobMaxBlocks - 1} {
+		id := azblob.NewBlockID(index)
+		c.Assert(len(id), chk.Equals, len(azblob.NewBlockID(0))) // All IDs have the same length
+		parsed, err := azblob.ParseBlockID(id)
+		c.Assert(err, chk.IsNil)
+		c.Assert(parsed, chk.Equals, index)
+	}
+	_, err := azblob.ParseBlockID(base64.StdEncoding.EncodeToString([]byte("short")))
+	c.Assert(err, chk.NotNil)
+}
+
+func (b *BlockBlobURLSuite) TestBlockLimitsValidatedBeforeSending(c *chk.C) {
+	tries := 0
+	service := fakePolicy(func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+		tries++
+		return newFakeResponse(request, http.StatusCreated, nil, ""), nil
+	})
+	p := pipeline.NewPipeline([]pipeline.Factory{pipeline.MethodFactoryMarker(), service}, pipeline.Options{})
+	u, _ := url.Parse("https://myaccount.blob.core.windows.net/mycontainer/myblob")
+	blob := azblob.NewBlockBlobURL(*u, p)
+
+	// A SectionReader reports its declared size without needing the data in memory
+	body := io.NewSectionReader(strings.NewReader(""), 0, azblob.BlockBlobMaxPutBlockBytes+1)
+	_, err := blob.PutBlock(ctx, azblob.NewBlockID(0), body, azblob.LeaseAccessConditions{})
+	c.Assert(err, chk.Equals, azblob.ErrBlockTooLarge)
+	c.Assert(tries, chk.Equals, 0)
+}
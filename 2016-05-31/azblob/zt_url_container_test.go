@@ -3,6 +3,7 @@ package azblob_test
 import (
 	"context"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/Azure/azure-storage-blob-go/2016-05-31/azblob"
@@ -333,3 +334,67 @@ func (s *ContainerURLSuite) TestListBlobsWithPrefix(c *chk.C) {
 	c.Assert(blobs.Blobs.BlobPrefix, chk.HasLen, 3)
 	c.Assert(blobs.Blobs.Blob, chk.HasLen, 0)
 }
+
+func (s *ContainerURLSuite) TestSetMetadataForPrefix(c *chk.C) {
+	bsu := getBSU()
+	container, _ := createNewContainer(c, bsu)
+	defer delContainer(c, container)
+
+	for _, prefix := range []string{"one/", "one/", "one/", "two/"} {
+		createBlockBlobWithPrefix(c, container, prefix)
+	}
+
+	metadata := azblob.Metadata{"migrated": "true"}
+	err := container.SetMetadataForPrefix(context.Background(), "one/", metadata, 2)
+	c.Assert(err, chk.IsNil)
+
+	blobs, err := container.ListBlobs(context.Background(), azblob.Marker{},
+		azblob.ListBlobsOptions{Details: azblob.BlobListingDetails{Metadata: true}})
+	c.Assert(err, chk.IsNil)
+	c.Assert(blobs.Blobs.Blob, chk.HasLen, 4)
+	for _, blob := range blobs.Blobs.Blob {
+		if strings.HasPrefix(blob.Name, "one/") {
+			c.Assert(blob.Metadata, chk.DeepEquals, metadata)
+		} else {
+			c.Assert(blob.Metadata, chk.HasLen, 0)
+		}
+	}
+}
+
+func (s *ContainerURLSuite) TestAcquireInfiniteLease(c *chk.C) {
+	bsu := getBSU()
+	container, _ := createNewContainer(c, bsu)
+	defer delContainer(c, container)
+
+	resp, err := container.AcquireInfiniteLease(context.Background(), "", azblob.HTTPAccessConditions{})
+	c.Assert(err, chk.IsNil)
+	props, err := container.GetPropertiesAndMetadata(context.Background(), azblob.LeaseAccessConditions{})
+	c.Assert(err, chk.IsNil)
+	c.Assert(props.LeaseDuration(), chk.Equals, azblob.LeaseDurationInfinite)
+
+	_, err = container.ReleaseLease(context.Background(), resp.LeaseID(), azblob.HTTPAccessConditions{})
+	c.Assert(err, chk.IsNil)
+}
+
+func (s *ContainerURLSuite) TestGetAnonymousAccess(c *chk.C) {
+	bsu := getBSU()
+	container, _ := createNewContainer(c, bsu)
+	defer delContainer(c, container)
+	_, blobName := createNewBlockBlob(c, container)
+	anonymous := azblob.NewContainerURL(container.URL(), azblob.NewPipeline(azblob.NewAnonymousCredential(), azblob.PipelineOptions{}))
+
+	for _, accessType := range []azblob.PublicAccessType{azblob.PublicAccessContainer, azblob.PublicAccessBlob, azblob.PublicAccessNone} {
+		_, err := container.SetPermissions(ctx, accessType, nil, azblob.ContainerAccessConditions{})
+		c.Assert(err, chk.IsNil)
+		access, err := anonymous.GetAnonymousAccess(ctx, blobName)
+		c.Assert(err, chk.IsNil)
+		c.Assert(access, chk.Equals, accessType)
+	}
+
+	// Without a blob to probe, blob-level access can't be told apart from no access
+	_, err := container.SetPermissions(ctx, azblob.PublicAccessBlob, nil, azblob.ContainerAccessConditions{})
+	c.Assert(err, chk.IsNil)
+	access, err := anonymous.GetAnonymousAccess(ctx, "")
+	c.Assert(err, chk.IsNil)
+	c.Assert(access, chk.Equals, azblob.PublicAccessNone)
+}
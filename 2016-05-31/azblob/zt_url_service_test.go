@@ -2,7 +2,9 @@ package azblob_test
 
 import (
 	"context"
+	"time"
 
+	"github.com/Azure/azure-pipeline-go/pipeline"
 	"github.com/Azure/azure-storage-blob-go/2016-05-31/azblob"
 	chk "gopkg.in/check.v1" // go get gopkg.in/check.v1
 )
@@ -107,3 +109,15 @@ func (s *StorageAccountSuite) TestListContainersPaged(c *chk.C) {
 		marker = resp.NextMarker
 	}
 }
+
+func (s *StorageAccountSuite) TestDefaultPipelineOptions(c *chk.C) {
+	o := azblob.DefaultPipelineOptions()
+	c.Assert(o.Retry.Policy, chk.Equals, azblob.RetryPolicyExponential)
+	c.Assert(o.Retry.MaxTries, chk.Equals, int32(4))
+	c.Assert(o.Retry.RetryReadsFromSecondaryHost, chk.Equals, "")
+	c.Assert(o.Retry.TryTimeout, chk.Equals, 30*time.Second)
+	c.Assert(o.RequestLog.LogWarningIfTryOverThreshold, chk.Equals, 3*time.Second)
+	c.Assert(o.Log.MinimumSeverityToLog(), chk.Equals, pipeline.LogWarning)
+
+	c.Assert(azblob.NewDefaultPipeline(azblob.NewAnonymousCredential()), chk.NotNil)
+}
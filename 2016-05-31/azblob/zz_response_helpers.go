@@ -3,6 +3,9 @@ package azblob
 import (
 	"crypto/md5"
 	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -90,6 +93,50 @@ func (gr GetResponse) BlobContentMD5() [md5.Size]byte {
 	return md5StringToMD5(gr.rawResponse.Header.Get("x-ms-blob-content-md5"))
 }
 
+// BlobContentLength returns the size of the whole blob: the total from the Content-Range header
+// ("bytes <first>-<last>/<total>") of a ranged GET or, if the GET wasn't ranged, the Content-Length.
+// It returns -1 if the size isn't known (for example, if Content-Range is malformed).
+func (gr GetResponse) BlobContentLength() int64 {
+	contentRange := gr.rawResponse.Header.Get("Content-Range")
+	if contentRange == "" {
+		return gr.ContentLength()
+	}
+	total, ok := parseContentRangeTotal(contentRange)
+	if !ok {
+		return -1
+	}
+	return total
+}
+
+// parseContentRangeTotal parses a Content-Range value ("bytes <first>-<last>/<total>") and returns its total.
+func parseContentRangeTotal(contentRange string) (int64, bool) {
+	const prefix = "bytes "
+	if !strings.HasPrefix(contentRange, prefix) {
+		return 0, false
+	}
+	slash := strings.IndexByte(contentRange, '/')
+	if slash == -1 {
+		return 0, false
+	}
+	byteRange := strings.Split(contentRange[len(prefix):slash], "-")
+	if len(byteRange) != 2 {
+		return 0, false
+	}
+	first, err1 := strconv.ParseInt(byteRange[0], 10, 64)
+	last, err2 := strconv.ParseInt(byteRange[1], 10, 64)
+	total, err3 := strconv.ParseInt(contentRange[slash+1:], 10, 64)
+	if err1 != nil || err2 != nil || err3 != nil || first < 0 || last < first || total <= last {
+		return 0, false
+	}
+	return total, true
+}
+
+// NotModified returns true if the service returned 304 (Not Modified) because the blob matched the
+// request's IfNoneMatch/IfModifiedSince conditions; the response has no body.
+func (gr GetResponse) NotModified() bool {
+	return gr.rawResponse.StatusCode == http.StatusNotModified
+}
+
 // ContentMD5 returns the value for header Content-MD5.
 func (gr GetResponse) ContentMD5() [md5.Size]byte {
 	return md5StringToMD5(gr.rawResponse.Header.Get("Content-MD5"))
@@ -112,3 +159,63 @@ func (bgpr BlobsGetPropertiesResponse) DestinationSnapshot() time.Time {
 	}
 	return time.Time{}
 }
+
+// CopyProgressBytes returns the bytes copied and total bytes from header x-ms-copy-progress.
+// Both are 0 if the blob has no copy progress.
+func (bgpr BlobsGetPropertiesResponse) CopyProgressBytes() (bytesCopied, totalBytes int64) {
+	bytesCopied, totalBytes, _ = parseCopyProgress(bgpr.CopyProgress())
+	return
+}
+
+// CopyProgressBytes returns the bytes copied and total bytes from header x-ms-copy-progress.
+// Both are 0 if the blob has no copy progress.
+func (gr GetResponse) CopyProgressBytes() (bytesCopied, totalBytes int64) {
+	bytesCopied, totalBytes, _ = parseCopyProgress(gr.CopyProgress())
+	return
+}
+
+// IncrementalCopy returns the value for header x-ms-incremental-copy as a bool.
+func (bgpr BlobsGetPropertiesResponse) IncrementalCopy() bool {
+	return strings.EqualFold(bgpr.IsIncrementalCopy(), "true")
+}
+
+// RemainingBlockCount returns the number of blocks that can still be appended to the append blob
+// (AppendBlobMaxBlocks minus the value of header x-ms-blob-committed-block-count); it is -1 if the header is absent.
+func (ababr AppendBlobsAppendBlockResponse) RemainingBlockCount() int32 {
+	return remainingAppendBlocks(ababr.BlobCommittedBlockCount())
+}
+
+// RemainingBlockCount returns the number of blocks that can still be appended to an append blob
+// (AppendBlobMaxBlocks minus the value of header x-ms-blob-committed-block-count); it is -1 if the blob isn't an append blob.
+func (bgpr BlobsGetPropertiesResponse) RemainingBlockCount() int32 {
+	return remainingAppendBlocks(bgpr.BlobCommittedBlockCount())
+}
+
+func remainingAppendBlocks(committedBlockCount string) int32 {
+	if committedBlockCount == "" {
+		return -1
+	}
+	count, err := strconv.ParseInt(committedBlockCount, 10, 32)
+	if err != nil {
+		panic(err)
+	}
+	return AppendBlobMaxBlocks - int32(count)
+}
+
+// ServerEncrypted returns the value for header x-ms-server-encrypted as a bool.
+func (bgpr BlobsGetPropertiesResponse) ServerEncrypted() bool {
+	return strings.EqualFold(bgpr.IsServerEncrypted(), "true")
+}
+
+// SequenceNumber returns the value for header x-ms-blob-sequence-number as an int64; it is -1 if the blob isn't a page blob.
+func (bgpr BlobsGetPropertiesResponse) SequenceNumber() int64 {
+	s := bgpr.BlobSequenceNumber()
+	if s == "" {
+		return -1
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}